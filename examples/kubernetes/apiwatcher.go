@@ -2,91 +2,167 @@ package kubernetes
 
 import (
 	"context"
+	"fmt"
 
 	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/informers"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
 
 func (k *Kubernetes) Informers() map[string]k8sapi.InformerFunc {
-	infuncs := make(map[string]k8sapi.InformerFunc)
-
-	infuncs["service"] = func(ctx context.Context, client kubernetes.Interface) *k8sapi.Informer {
-		svcLister, svcController := object.NewIndexerInformer(
-			&cache.ListWatch{
-				ListFunc:  serviceListFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-				WatchFunc: serviceWatchFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-			},
-			&api.Service{},
-			cache.ResourceEventHandlerFuncs{
-				AddFunc:    k.APIConn.(*dnsControl).Add,
-				UpdateFunc: k.APIConn.(*dnsControl).Update,
-				DeleteFunc: k.APIConn.(*dnsControl).Delete,
-			},
-			cache.Indexers{svcNameNamespaceIndex: svcNameNamespaceIndexFunc, svcIPIndex: svcIPIndexFunc},
-			object.DefaultProcessor(object.ToService(k.opts.skipAPIObjectsCleanup), nil),
-		)
-		return &k8sapi.Informer{Controller: svcController, Lister: svcLister}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    k.APIConn.(*dnsControl).Add,
+		UpdateFunc: k.APIConn.(*dnsControl).Update,
+		DeleteFunc: k.APIConn.(*dnsControl).Delete,
+	}
+	infuncs := map[string]k8sapi.InformerFunc{
+		informers.Service: informers.ServiceInformer(informers.Options{
+			LabelSelector: k.opts.selector,
+			Convert:       k8sapi.ConvertFunc(object.ToService(k.opts.skipAPIObjectsCleanup)),
+			Indexers:      svcIndexers(),
+			Handler:       handler,
+		}),
+		informers.Namespace: informers.NamespaceInformer(informers.Options{LabelSelector: k.opts.namespaceSelector}),
 	}
-
 	if k.opts.initPodCache {
-		infuncs["pod"] = func(ctx context.Context, client kubernetes.Interface) *k8sapi.Informer {
-			podLister, podController := object.NewIndexerInformer(
-				&cache.ListWatch{
-					ListFunc:  podListFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-					WatchFunc: podWatchFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-				},
-				&api.Pod{},
-				cache.ResourceEventHandlerFuncs{
-					AddFunc:    k.APIConn.(*dnsControl).Add,
-					UpdateFunc: k.APIConn.(*dnsControl).Update,
-					DeleteFunc: k.APIConn.(*dnsControl).Delete,
-				},
-				cache.Indexers{podIPIndex: podIPIndexFunc},
-				object.DefaultProcessor(object.ToPod(k.opts.skipAPIObjectsCleanup), nil),
-			)
-			return &k8sapi.Informer{Controller: podController, Lister: podLister}
+		infuncs[informers.Pod] = informers.PodInformer(informers.Options{
+			LabelSelector: k.opts.selector,
+			FieldSelector: podFieldSelector,
+			Convert:       k8sapi.ConvertFunc(object.ToPod(k.opts.skipAPIObjectsCleanup)),
+			Indexers:      podIndexers(),
+			Handler:       handler,
+		})
+	}
+	if k.opts.initEndpointsCache {
+		// Both Endpoints and EndpointSlices are registered, but only the type used with the cluster is watched.
+		dns := k.APIConn.(*dnsControl)
+		epInformer := informers.EndpointsInformer(informers.Options{
+			LabelSelector: k.opts.selector,
+			Convert:       k8sapi.ConvertFunc(object.ToEndpoints(k.opts.skipAPIObjectsCleanup)),
+			Indexers:      epIndexers(),
+			Handler:       handler,
+			Observe:       dns.recordDNSProgrammingLatency,
+		})
+		sliceInformer := informers.EndpointSliceInformer(informers.Options{
+			LabelSelector: k.opts.selector,
+			Convert:       k8sapi.ConvertFunc(object.ToEndpointSlice(k.opts.skipAPIObjectsCleanup)),
+			Indexers:      sliceIndexers(),
+			Handler:       handler,
+			Observe:       dns.recordDNSProgrammingLatency,
+		})
+		useSlices := func(client kubernetes.Interface, opts k8sapi.InformerOptions) bool {
+			use := endpointSlicesSupported(client, opts)
+			dns.setEndpointSlices(use)
+			return use
+		}
+		infuncs[informers.Endpoints] = func(ctx context.Context, client kubernetes.Interface, opts k8sapi.InformerOptions) *k8sapi.Informer {
+			if useSlices(client, opts) {
+				return emptyInformer(opts, &api.EndpointsList{}, &api.Endpoints{}, epIndexers())
+			}
+			return epInformer(ctx, client, opts)
+		}
+		infuncs[informers.EndpointSlice] = func(ctx context.Context, client kubernetes.Interface, opts k8sapi.InformerOptions) *k8sapi.Informer {
+			if !useSlices(client, opts) {
+				return emptyInformer(opts, &discovery.EndpointSliceList{}, &discovery.EndpointSlice{}, sliceIndexers())
+			}
+			return sliceInformer(ctx, client, opts)
 		}
 	}
+	return infuncs
+}
 
-	if k.opts.initEndpointsCache {
-		infuncs["endpoints"] = func(ctx context.Context, client kubernetes.Interface) *k8sapi.Informer {
-			epLister, epController := object.NewIndexerInformer(
-				&cache.ListWatch{
-					ListFunc:  endpointsListFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-					WatchFunc: endpointsWatchFunc(ctx, client, api.NamespaceAll, k.opts.selector),
-				},
-				&api.Endpoints{},
-				cache.ResourceEventHandlerFuncs{
-					AddFunc:    k.APIConn.(*dnsControl).Add,
-					UpdateFunc: k.APIConn.(*dnsControl).Update,
-					DeleteFunc: k.APIConn.(*dnsControl).Delete,
-				},
-				cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc, epIPIndex: epIPIndexFunc},
-				object.DefaultProcessor(object.ToEndpoints(k.opts.skipAPIObjectsCleanup), k.APIConn.(*dnsControl).recordDNSProgrammingLatency),
-			)
-			return &k8sapi.Informer{Controller: epController, Lister: epLister}
+// endpointSliceKey is the InformerOptions.BuildValue key of whether the cluster serves EndpointSlices.
+type endpointSliceKey struct{}
+
+// endpointSlicesSupported returns whether the cluster of client serves EndpointSlices, as found by the first call
+// for the build of the Informers, so that the endpoints and endpointslice InformerFuncs of a build agree on the type
+// watched even if a discovery request fails, as do the InformerFuncs called again when a Corefile reload reuses the
+// running Informers.
+func endpointSlicesSupported(client kubernetes.Interface, opts k8sapi.InformerOptions) bool {
+	return opts.BuildValue(endpointSliceKey{}, func() interface{} { return endpointSliceSupported(client) }).(bool)
+}
+
+// endpointSliceSupported returns true if the cluster serves discovery.k8s.io/v1beta1 EndpointSlices.
+func endpointSliceSupported(client kubernetes.Interface) bool {
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(discovery.SchemeGroupVersion.String())
+	if apierrors.IsNotFound(err) {
+		log.Infof("The cluster does not serve %s, watching Endpoints", discovery.SchemeGroupVersion)
+		return false
+	}
+	if err != nil {
+		log.Warningf("Failed to discover EndpointSlices, watching Endpoints: %v", err)
+		return false
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == "endpointslices" {
+			return true
 		}
 	}
+	return false
+}
+
+// emptyInformer returns an Informer whose store stays empty, for a type that is registered but not used with the
+// cluster. Its lists return the empty list, and its watches never send events.
+func emptyInformer(opts k8sapi.InformerOptions, list, objType runtime.Object, indexers cache.Indexers) *k8sapi.Informer {
+	lw := opts.ListerWatcher(func(string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc:  func(meta.ListOptions) (runtime.Object, error) { return list.DeepCopyObject(), nil },
+			WatchFunc: func(meta.ListOptions) (watch.Interface, error) { return watch.NewFake(), nil },
+		}
+	})
+	store, controller := cache.NewIndexerInformer(lw, objType, defaultResyncPeriod, opts.EventHandler(nil), indexers)
+	return &k8sapi.Informer{Controller: controller, Lister: store}
+}
 
-	infuncs["namespace"] = func(ctx context.Context, client kubernetes.Interface) *k8sapi.Informer {
-		nsLister, nsController := cache.NewInformer(
-			&cache.ListWatch{
-				ListFunc:  namespaceListFunc(ctx, client, k.opts.namespaceSelector),
-				WatchFunc: namespaceWatchFunc(ctx, client, k.opts.namespaceSelector),
-			},
-			&api.Namespace{},
-			defaultResyncPeriod,
-			cache.ResourceEventHandlerFuncs{})
-		return &k8sapi.Informer{Controller: nsController, Lister: nsLister}
+// podFieldSelector selects the pods that are not terminated.
+var podFieldSelector = fields.ParseSelectorOrDie("status.phase!=Succeeded,status.phase!=Failed,status.phase!=Unknown")
+
+// Registrations implements the k8sapi.Registrar interface.
+func (k *Kubernetes) Registrations() map[string]k8sapi.Registration {
+	opts := k.informerOptions()
+	regs := map[string]k8sapi.Registration{
+		"service": {
+			Object:   &object.Service{},
+			Indexers: svcIndexers(),
+			Options:  opts,
+		},
+		"namespace": {Object: &api.Namespace{}, Options: opts},
+	}
+	if k.opts.initPodCache {
+		regs["pod"] = k8sapi.Registration{
+			Object:   &object.Pod{},
+			Indexers: podIndexers(),
+			Options:  opts,
+		}
 	}
+	if k.opts.initEndpointsCache {
+		regs["endpoints"] = k8sapi.Registration{
+			Object:   &object.Endpoints{},
+			Indexers: epIndexers(),
+			Options:  opts,
+		}
+		regs["endpointslice"] = k8sapi.Registration{
+			Object:   &object.EndpointSlice{},
+			Indexers: sliceIndexers(),
+			Options:  opts,
+		}
+	}
+	return regs
+}
 
-	return infuncs
+// informerOptions identifies the plugin options the Informers are built with, so that k8s_api only reuses
+// running Informers after a reload if they are unchanged.
+func (k *Kubernetes) informerOptions() string {
+	return fmt.Sprintf("labels=%v namespace_labels=%v skip_cleanup=%t", k.opts.selector, k.opts.namespaceSelector, k.opts.skipAPIObjectsCleanup)
 }
 
 func (k *Kubernetes) SetIndexer(name string, lister cache.KeyListerGetter) error {
@@ -97,6 +173,22 @@ func (k *Kubernetes) SetHasSynced(syncedFunc k8sapi.HasSyncedFunc) {
 	k.APIConn.(*dnsControl).syncedFn = syncedFunc
 }
 
+// The indexers of the Informers, which their registrations also declare.
+
+func svcIndexers() cache.Indexers {
+	return cache.Indexers{svcNameNamespaceIndex: svcNameNamespaceIndexFunc, svcIPIndex: svcIPIndexFunc}
+}
+
+func podIndexers() cache.Indexers { return cache.Indexers{podIPIndex: podIPIndexFunc} }
+
+func epIndexers() cache.Indexers {
+	return cache.Indexers{epNameNamespaceIndex: epNameNamespaceIndexFunc, epIPIndex: epIPIndexFunc}
+}
+
+func sliceIndexers() cache.Indexers {
+	return cache.Indexers{epNameNamespaceIndex: sliceNameNamespaceIndexFunc, epIPIndex: sliceIPIndexFunc}
+}
+
 func podIPIndexFunc(obj interface{}) ([]string, error) {
 	p, ok := obj.(*object.Pod)
 	if !ok {
@@ -110,11 +202,10 @@ func svcIPIndexFunc(obj interface{}) ([]string, error) {
 	if !ok {
 		return nil, errObj
 	}
-	if len(svc.ExternalIPs) == 0 {
-		return []string{svc.ClusterIP}, nil
-	}
-
-	return append([]string{svc.ClusterIP}, svc.ExternalIPs...), nil
+	ips := svc.ClusterIPList()
+	idx := make([]string, 0, len(ips)+len(svc.ExternalIPs))
+	idx = append(idx, ips...)
+	return append(idx, svc.ExternalIPs...), nil
 }
 
 func svcNameNamespaceIndexFunc(obj interface{}) ([]string, error) {
@@ -141,46 +232,18 @@ func epIPIndexFunc(obj interface{}) ([]string, error) {
 	return ep.IndexIP, nil
 }
 
-func serviceListFunc(ctx context.Context, c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
-	return func(opts meta.ListOptions) (runtime.Object, error) {
-		if s != nil {
-			opts.LabelSelector = s.String()
-		}
-		listV1, err := c.CoreV1().Services(ns).List(ctx, opts)
-		return listV1, err
-	}
-}
-
-func podListFunc(ctx context.Context, c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
-	return func(opts meta.ListOptions) (runtime.Object, error) {
-		if s != nil {
-			opts.LabelSelector = s.String()
-		}
-		if len(opts.FieldSelector) > 0 {
-			opts.FieldSelector = opts.FieldSelector + ","
-		}
-		opts.FieldSelector = opts.FieldSelector + "status.phase!=Succeeded,status.phase!=Failed,status.phase!=Unknown"
-		listV1, err := c.CoreV1().Pods(ns).List(ctx, opts)
-		return listV1, err
-	}
-}
-
-func endpointsListFunc(ctx context.Context, c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
-	return func(opts meta.ListOptions) (runtime.Object, error) {
-		if s != nil {
-			opts.LabelSelector = s.String()
-		}
-		listV1, err := c.CoreV1().Endpoints(ns).List(ctx, opts)
-		return listV1, err
+func sliceNameNamespaceIndexFunc(obj interface{}) ([]string, error) {
+	s, ok := obj.(*object.EndpointSlice)
+	if !ok {
+		return nil, errObj
 	}
+	return []string{s.Index}, nil
 }
 
-func namespaceListFunc(ctx context.Context, c kubernetes.Interface, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
-	return func(opts meta.ListOptions) (runtime.Object, error) {
-		if s != nil {
-			opts.LabelSelector = s.String()
-		}
-		listV1, err := c.CoreV1().Namespaces().List(ctx, opts)
-		return listV1, err
+func sliceIPIndexFunc(obj interface{}) ([]string, error) {
+	s, ok := obj.(*object.EndpointSlice)
+	if !ok {
+		return nil, errObj
 	}
+	return s.IndexIP, nil
 }
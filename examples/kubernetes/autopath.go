@@ -2,7 +2,7 @@ package kubernetes
 
 import (
 	"github.com/coredns/coredns/plugin"
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/coredns/coredns/request"
 )
 
@@ -7,9 +7,11 @@ import (
 	"time"
 
 	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/listers"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 
 	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
@@ -49,11 +51,17 @@ type dnsControl struct {
 	// aligned ( we use sync.LoadAtomic with this )
 	modified int64
 
-	svcLister cache.Indexer
-	podLister cache.Indexer
-	epLister  cache.Indexer
+	svcLister *listers.ServiceLister
+	podLister *listers.PodLister
+	epLister  *listers.EndpointsLister
 	nsLister  cache.Store
 
+	// sliceLister is used instead of epLister if useEndpointSlices is set, which it is when the cluster serves
+	// EndpointSlices. The slices of each service are aggregated into its Endpoints. useEndpointSlices is set while
+	// the Informers are built, which may happen while queries are served, so it is accessed atomically.
+	sliceLister       *listers.EndpointSliceLister
+	useEndpointSlices int32
+
 	syncedFn k8sapi.HasSyncedFunc
 }
 
@@ -75,23 +83,29 @@ type dnsControlOpts struct {
 func (dns *dnsControl) SetLister(name string, lister cache.KeyListerGetter) error {
 	switch name {
 	case "service":
-		l, ok := lister.(cache.Indexer)
-		if !ok {
-			return fmt.Errorf("expected Indexer, got %v", lister)
+		l, err := listers.NewServiceLister(lister)
+		if err != nil {
+			return err
 		}
 		dns.svcLister = l
 	case "pod":
-		l, ok := lister.(cache.Indexer)
-		if !ok {
-			return fmt.Errorf("expected Indexer, got %v", lister)
+		l, err := listers.NewPodLister(lister)
+		if err != nil {
+			return err
 		}
 		dns.podLister = l
 	case "endpoints":
-		l, ok := lister.(cache.Indexer)
-		if !ok {
-			return fmt.Errorf("expected Indexer, got %v", lister)
+		l, err := listers.NewEndpointsLister(lister)
+		if err != nil {
+			return err
 		}
 		dns.epLister = l
+	case "endpointslice":
+		l, err := listers.NewEndpointSliceLister(lister)
+		if err != nil {
+			return err
+		}
+		dns.sliceLister = l
 	case "namespace":
 		l, ok := lister.(cache.Store)
 		if !ok {
@@ -104,7 +118,7 @@ func (dns *dnsControl) SetLister(name string, lister cache.KeyListerGetter) erro
 }
 
 func (dns *dnsControl) recordDNSProgrammingLatency(obj meta.Object) {
-	recordDNSProgrammingLatency(dns.getServices(obj.(*api.Endpoints)), obj.(*api.Endpoints))
+	recordDNSProgrammingLatency(dns.getServices(obj), obj)
 }
 
 // HasSynced returns the sync status
@@ -115,104 +129,79 @@ func (dns *dnsControl) HasSynced() bool {
 	return dns.syncedFn()
 }
 
-func (dns *dnsControl) ServiceList() (svcs []*object.Service) {
-	os := dns.svcLister.List()
-	for _, o := range os {
-		s, ok := o.(*object.Service)
-		if !ok {
-			continue
-		}
-		svcs = append(svcs, s)
+// endpointSlices returns true if endpoints are read from EndpointSlices.
+func (dns *dnsControl) endpointSlices() bool { return atomic.LoadInt32(&dns.useEndpointSlices) == 1 }
+
+// setEndpointSlices sets whether endpoints are read from EndpointSlices.
+func (dns *dnsControl) setEndpointSlices(use bool) {
+	var v int32
+	if use {
+		v = 1
 	}
-	return svcs
+	atomic.StoreInt32(&dns.useEndpointSlices, v)
 }
 
-func (dns *dnsControl) EndpointsList() (eps []*object.Endpoints) {
-	os := dns.epLister.List()
-	for _, o := range os {
-		ep, ok := o.(*object.Endpoints)
-		if !ok {
-			continue
-		}
-		eps = append(eps, ep)
+func (dns *dnsControl) ServiceList() []*object.Service { return dns.svcLister.List() }
+
+func (dns *dnsControl) EndpointsList() []*object.Endpoints {
+	if dns.endpointSlices() {
+		return object.EndpointsFromSlices(dns.sliceLister.List())
 	}
-	return eps
+	return dns.epLister.List()
 }
 
-func (dns *dnsControl) PodIndex(ip string) (pods []*object.Pod) {
-	os, err := dns.podLister.ByIndex(podIPIndex, ip)
+func (dns *dnsControl) PodIndex(ip string) []*object.Pod {
+	pods, err := dns.podLister.ByIndex(podIPIndex, ip)
 	if err != nil {
 		return nil
 	}
-	for _, o := range os {
-		p, ok := o.(*object.Pod)
-		if !ok {
-			continue
-		}
-		pods = append(pods, p)
-	}
 	return pods
 }
 
-func (dns *dnsControl) SvcIndex(idx string) (svcs []*object.Service) {
-	os, err := dns.svcLister.ByIndex(svcNameNamespaceIndex, idx)
+func (dns *dnsControl) SvcIndex(idx string) []*object.Service {
+	svcs, err := dns.svcLister.ByIndex(svcNameNamespaceIndex, idx)
 	if err != nil {
 		return nil
 	}
-	for _, o := range os {
-		s, ok := o.(*object.Service)
-		if !ok {
-			continue
-		}
-		svcs = append(svcs, s)
-	}
 	return svcs
 }
 
-func (dns *dnsControl) SvcIndexReverse(ip string) (svcs []*object.Service) {
-	os, err := dns.svcLister.ByIndex(svcIPIndex, ip)
+func (dns *dnsControl) SvcIndexReverse(ip string) []*object.Service {
+	svcs, err := dns.svcLister.ByIndex(svcIPIndex, ip)
 	if err != nil {
 		return nil
 	}
-
-	for _, o := range os {
-		s, ok := o.(*object.Service)
-		if !ok {
-			continue
-		}
-		svcs = append(svcs, s)
-	}
 	return svcs
 }
 
-func (dns *dnsControl) EpIndex(idx string) (ep []*object.Endpoints) {
-	os, err := dns.epLister.ByIndex(epNameNamespaceIndex, idx)
+func (dns *dnsControl) EpIndex(idx string) []*object.Endpoints {
+	if dns.endpointSlices() {
+		slices, err := dns.sliceLister.ByIndex(epNameNamespaceIndex, idx)
+		if err != nil {
+			return nil
+		}
+		return object.EndpointsFromSlices(slices)
+	}
+	eps, err := dns.epLister.ByIndex(epNameNamespaceIndex, idx)
 	if err != nil {
 		return nil
 	}
-	for _, o := range os {
-		e, ok := o.(*object.Endpoints)
-		if !ok {
-			continue
-		}
-		ep = append(ep, e)
-	}
-	return ep
+	return eps
 }
 
-func (dns *dnsControl) EpIndexReverse(ip string) (ep []*object.Endpoints) {
-	os, err := dns.epLister.ByIndex(epIPIndex, ip)
+func (dns *dnsControl) EpIndexReverse(ip string) []*object.Endpoints {
+	if dns.endpointSlices() {
+		slices, err := dns.sliceLister.ByIndex(epIPIndex, ip)
+		if err != nil {
+			return nil
+		}
+		return object.EndpointsFromSlices(slices)
+	}
+	eps, err := dns.epLister.ByIndex(epIPIndex, ip)
 	if err != nil {
 		return nil
 	}
-	for _, o := range os {
-		e, ok := o.(*object.Endpoints)
-		if !ok {
-			continue
-		}
-		ep = append(ep, e)
-	}
-	return ep
+	return eps
 }
 
 // GetNamespaceByName returns the namespace by name. If nothing is found an error is returned.
@@ -253,37 +242,33 @@ func (dns *dnsControl) detectChanges(oldObj, newObj interface{}) {
 		if !endpointsEquivalent(oldObj.(*object.Endpoints), newObj.(*object.Endpoints)) {
 			dns.updateModifed()
 		}
+	case *object.EndpointSlice:
+		if !endpointSlicesEquivalent(oldObj.(*object.EndpointSlice), newObj.(*object.EndpointSlice)) {
+			dns.updateModifed()
+		}
 	default:
 		log.Warningf("Updates for %T not supported.", ob)
 	}
 }
 
-func (dns *dnsControl) getServices(endpoints *api.Endpoints) []*object.Service {
-	return dns.SvcIndex(object.EndpointsKey(endpoints.GetName(), endpoints.GetNamespace()))
+// getServices returns the services of an *api.Endpoints or *discovery.EndpointSlice.
+func (dns *dnsControl) getServices(endpoints meta.Object) []*object.Service {
+	name := endpoints.GetName()
+	if _, ok := endpoints.(*discovery.EndpointSlice); ok {
+		name = endpoints.GetLabels()[discovery.LabelServiceName]
+	}
+	return dns.SvcIndex(object.EndpointsKey(name, endpoints.GetNamespace()))
 }
 
 // subsetsEquivalent checks if two endpoint subsets are significantly equivalent
-// I.e. that they have the same ready addresses, host names, ports (including protocol
-// and service names for SRV)
+// I.e. that they have the same ready and not ready addresses, host names, ports
+// (including protocol and service names for SRV)
 func subsetsEquivalent(sa, sb object.EndpointSubset) bool {
-	if len(sa.Addresses) != len(sb.Addresses) {
-		return false
-	}
 	if len(sa.Ports) != len(sb.Ports) {
 		return false
 	}
-
-	// in Addresses and Ports, we should be able to rely on
-	// these being sorted and able to be compared
-	// they are supposed to be in a canonical format
-	for addr, aaddr := range sa.Addresses {
-		baddr := sb.Addresses[addr]
-		if aaddr.IP != baddr.IP {
-			return false
-		}
-		if aaddr.Hostname != baddr.Hostname {
-			return false
-		}
+	if !addressesEquivalent(sa.Addresses, sb.Addresses) || !addressesEquivalent(sa.NotReadyAddresses, sb.NotReadyAddresses) {
+		return false
 	}
 
 	for port, aport := range sa.Ports {
@@ -301,6 +286,27 @@ func subsetsEquivalent(sa, sb object.EndpointSubset) bool {
 	return true
 }
 
+// addressesEquivalent checks if two lists of endpoint addresses have the same
+// addresses and host names.
+func addressesEquivalent(a, b []object.EndpointAddress) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	// in Addresses, we should be able to rely on
+	// these being sorted and able to be compared
+	// they are supposed to be in a canonical format
+	for i, aaddr := range a {
+		baddr := b[i]
+		if aaddr.IP != baddr.IP {
+			return false
+		}
+		if aaddr.Hostname != baddr.Hostname {
+			return false
+		}
+	}
+	return true
+}
+
 // endpointsEquivalent checks if the update to an endpoint is something
 // that matters to us or if they are effectively equivalent.
 func endpointsEquivalent(a, b *object.Endpoints) bool {
@@ -324,6 +330,16 @@ func endpointsEquivalent(a, b *object.Endpoints) bool {
 	return true
 }
 
+// endpointSlicesEquivalent checks if the update to an endpoint slice is something
+// that matters to us or if they are effectively equivalent.
+func endpointSlicesEquivalent(a, b *object.EndpointSlice) bool {
+	if a == nil || b == nil || a.Index != b.Index {
+		return false
+	}
+	return subsetsEquivalent(object.EndpointSubset{Addresses: a.Addresses, NotReadyAddresses: a.NotReadyAddresses, Ports: a.Ports},
+		object.EndpointSubset{Addresses: b.Addresses, NotReadyAddresses: b.NotReadyAddresses, Ports: b.Ports})
+}
+
 func (dns *dnsControl) Modified() int64 {
 	unix := atomic.LoadInt64(&dns.modified)
 	return unix
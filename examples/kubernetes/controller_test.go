@@ -6,11 +6,15 @@ import (
 	"strconv"
 	"testing"
 
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/miekg/dns"
 	api "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/tools/cache"
@@ -38,13 +42,13 @@ func BenchmarkController(b *testing.B) {
 	dnsCon := new(dnsControl)
 	k.APIConn = dnsCon
 	informerFuncs := k.Informers()
-	epInformer := informerFuncs["endpoints"](ctx, client)
-	svcInformer := informerFuncs["service"](ctx, client)
-	nsInformer := informerFuncs["namespace"](ctx, client)
+	epInformer := informerFuncs["endpoints"](ctx, client, k8sapi.InformerOptions{})
+	svcInformer := informerFuncs["service"](ctx, client, k8sapi.InformerOptions{})
+	nsInformer := informerFuncs["namespace"](ctx, client, k8sapi.InformerOptions{})
 
-	dnsCon.epLister = epInformer.Lister.(cache.Indexer)
-	dnsCon.svcLister = svcInformer.Lister.(cache.Indexer)
-	dnsCon.nsLister = nsInformer.Lister.(cache.Store)
+	dnsCon.SetLister("endpoints", epInformer.Lister)
+	dnsCon.SetLister("service", svcInformer.Lister)
+	dnsCon.SetLister("namespace", nsInformer.Lister)
 
 	// Add resources
 	cidr := "10.0.0.0/19"
@@ -67,6 +71,118 @@ func BenchmarkController(b *testing.B) {
 	close(stop)
 }
 
+func TestDualStackService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	k := New([]string{"cluster.local.", "0.10.in-addr.arpa.", "0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa."})
+	k.opts = dnsControlOpts{
+		zones:              k.Zones,
+		initEndpointsCache: true,
+	}
+	k.TransferTo = []string{"10.240.0.1:53"}
+
+	client := fake.NewSimpleClientset()
+	client.CoreV1().Namespaces().Create(ctx, &api.Namespace{ObjectMeta: meta.ObjectMeta{Name: "testns"}}, meta.CreateOptions{})
+	client.CoreV1().Services("testns").Create(ctx, &api.Service{
+		ObjectMeta: meta.ObjectMeta{
+			Name:      "svcdual",
+			Namespace: "testns",
+		},
+		Spec: api.ServiceSpec{
+			Type:       api.ServiceTypeClusterIP,
+			ClusterIP:  "10.0.0.3",
+			ClusterIPs: []string{"10.0.0.3", "1234:abcd::3"},
+			IPFamilies: []api.IPFamily{api.IPv4Protocol, api.IPv6Protocol},
+			Ports: []api.ServicePort{{
+				Name:     "http",
+				Protocol: "tcp",
+				Port:     80,
+			}},
+		},
+	}, meta.CreateOptions{})
+
+	dnsCon := new(dnsControl)
+	k.APIConn = dnsCon
+	k.SetHasSynced(func() bool { return true })
+	informerFuncs := k.Informers()
+	for _, name := range []string{"endpoints", "service", "namespace"} {
+		informer := informerFuncs[name](ctx, client, k8sapi.InformerOptions{})
+		dnsCon.SetLister(name, informer.Lister)
+		go informer.Controller.Run(ctx.Done())
+		if !cache.WaitForCacheSync(ctx.Done(), informer.Controller.HasSynced) {
+			t.Fatalf("Informer %s did not sync", name)
+		}
+	}
+
+	tests := []test.Case{
+		{
+			Qname: "svcdual.testns.svc.cluster.local.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.A("svcdual.testns.svc.cluster.local.	5	IN	A	10.0.0.3"),
+			},
+		},
+		{
+			Qname: "svcdual.testns.svc.cluster.local.", Qtype: dns.TypeAAAA,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.AAAA("svcdual.testns.svc.cluster.local.	5	IN	AAAA	1234:abcd::3"),
+			},
+		},
+		{
+			Qname: "3.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.PTR("3.0.0.10.in-addr.arpa.	5	IN	PTR	svcdual.testns.svc.cluster.local."),
+			},
+		},
+		{
+			Qname: "3.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa.", Qtype: dns.TypePTR,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.PTR("3.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.c.b.a.4.3.2.1.ip6.arpa.	5	IN	PTR	svcdual.testns.svc.cluster.local."),
+			},
+		},
+	}
+	for i, tc := range tests {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := k.ServeDNS(ctx, w, tc.Msg()); err != nil {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			continue
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{})
+	axfr := &dns.Msg{}
+	axfr.SetAxfr(k.Zones[0])
+	if _, err := k.ServeDNS(ctx, w, axfr); err != nil {
+		t.Fatal(err)
+	}
+	expected := []dns.RR{
+		test.A("svcdual.testns.svc.cluster.local.	5	IN	A	10.0.0.3"),
+		test.AAAA("svcdual.testns.svc.cluster.local.	5	IN	AAAA	1234:abcd::3"),
+		test.SRV("svcdual.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svcdual.testns.svc.cluster.local."),
+		test.SRV("_http._tcp.svcdual.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svcdual.testns.svc.cluster.local."),
+	}
+	got := []dns.RR{}
+	for _, resp := range w.Msgs {
+		for _, ans := range resp.Answer {
+			if ans.Header().Rrtype != dns.TypeSOA {
+				got = append(got, ans)
+			}
+		}
+	}
+	if diff := difference(expected, got); len(diff) != 0 {
+		t.Errorf("Unexpected records in zone transfer: %v", diff)
+	}
+	if diff := difference(got, expected); len(diff) != 0 {
+		t.Errorf("Missing records in zone transfer: %v", diff)
+	}
+}
+
 func generateEndpoints(cidr string, client kubernetes.Interface) {
 	// https://groups.google.com/d/msg/golang-nuts/zlcYA4qk-94/TWRFHeXJCcYJ
 	ip, ipnet, err := net.ParseCIDR(cidr)
@@ -189,3 +305,101 @@ func createExternalSvc(suffix int, client kubernetes.Interface, ip net.IP) {
 		},
 	}, meta.CreateOptions{})
 }
+
+func TestEndpointSlicesEquivalent(t *testing.T) {
+	sliceA := object.EndpointSlice{
+		Index:     "svc1.testns",
+		Addresses: []object.EndpointAddress{{IP: "1.2.3.4", Hostname: "foo"}},
+		Ports:     []object.EndpointPort{{Name: "http", Port: 80, Protocol: "TCP"}},
+	}
+	sliceB := sliceA
+	sliceB.Version = "2"
+	sliceC := sliceA
+	sliceC.Addresses = []object.EndpointAddress{{IP: "1.2.3.5", Hostname: "foo"}}
+	sliceD := sliceA
+	sliceD.NotReadyAddresses = []object.EndpointAddress{{IP: "1.2.3.5"}}
+	sliceE := sliceA
+	sliceE.Ports = []object.EndpointPort{{Name: "http", Port: 8080, Protocol: "TCP"}}
+	sliceF := sliceA
+	sliceF.Index = "svc2.testns"
+
+	tests := []struct {
+		equiv bool
+		a     *object.EndpointSlice
+		b     *object.EndpointSlice
+	}{
+		{true, &sliceA, &sliceB},
+		{false, &sliceA, &sliceC},
+		{false, &sliceA, &sliceD},
+		{false, &sliceA, &sliceE},
+		{false, &sliceA, &sliceF},
+		{false, &sliceA, nil},
+	}
+
+	for i, tc := range tests {
+		if tc.equiv && !endpointSlicesEquivalent(tc.a, tc.b) {
+			t.Errorf("Test %d: expected slices to be equivalent and they are not.", i)
+		}
+		if !tc.equiv && endpointSlicesEquivalent(tc.a, tc.b) {
+			t.Errorf("Test %d: expected slices to be seen as different but they were not.", i)
+		}
+	}
+}
+
+func TestEndpointSliceMode(t *testing.T) {
+	slices := cache.NewIndexer(cache.MetaNamespaceKeyFunc, sliceIndexers())
+	slices.Add(&object.EndpointSlice{Name: "svc1-b", Namespace: "testns", ServiceName: "svc1", Index: "svc1.testns",
+		IndexIP: []string{"10.0.0.2"}, Addresses: []object.EndpointAddress{{IP: "10.0.0.2"}}})
+	slices.Add(&object.EndpointSlice{Name: "svc1-a", Namespace: "testns", ServiceName: "svc1", Index: "svc1.testns",
+		IndexIP: []string{"10.0.0.1"}, Addresses: []object.EndpointAddress{{IP: "10.0.0.1"}}})
+	slices.Add(&object.EndpointSlice{Name: "svc2-a", Namespace: "testns", ServiceName: "svc2", Index: "svc2.testns",
+		IndexIP: []string{"10.0.1.1"}, Addresses: []object.EndpointAddress{{IP: "10.0.1.1"}}})
+
+	dns := new(dnsControl)
+	if err := dns.SetLister("endpoints", cache.NewIndexer(cache.MetaNamespaceKeyFunc, epIndexers())); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := dns.SetLister("endpointslice", slices); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if eps := dns.EndpointsList(); len(eps) != 0 {
+		t.Errorf("Expected no endpoints before slices are used, got %d", len(eps))
+	}
+
+	dns.setEndpointSlices(true)
+	if eps := dns.EndpointsList(); len(eps) != 2 {
+		t.Errorf("Expected endpoints of 2 services, got %d", len(eps))
+	}
+	eps := dns.EpIndex("svc1.testns")
+	if len(eps) != 1 || len(eps[0].Subsets) != 2 || eps[0].Name != "svc1" {
+		t.Fatalf("Expected the endpoints of svc1 with a subset per slice, got %+v", eps)
+	}
+	if eps[0].Subsets[0].Addresses[0].IP != "10.0.0.1" {
+		t.Errorf("Expected subsets in slice name order, got %+v", eps[0].Subsets)
+	}
+	eps = dns.EpIndexReverse("10.0.1.1")
+	if len(eps) != 1 || eps[0].Name != "svc2" {
+		t.Errorf("Expected the endpoints of svc2 by IP, got %+v", eps)
+	}
+	if eps := dns.EpIndexReverse("10.0.9.9"); len(eps) != 0 {
+		t.Errorf("Expected no endpoints for unknown IP, got %+v", eps)
+	}
+}
+
+func TestSliceSupport(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	discovery := client.Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*meta.APIResourceList{{
+		GroupVersion: "discovery.k8s.io/v1beta1",
+		APIResources: []meta.APIResource{{Name: "endpointslices"}},
+	}}
+	if !endpointSlicesSupported(client, k8sapi.InformerOptions{}) {
+		t.Fatal("Expected EndpointSlices to be supported")
+	}
+
+	// without a build, discovery is asked again, and a cluster without the group is watched through Endpoints
+	discovery.Resources = nil
+	if endpointSlicesSupported(client, k8sapi.InformerOptions{}) {
+		t.Error("Expected EndpointSlices not to be supported without discovery.k8s.io/v1beta1")
+	}
+}
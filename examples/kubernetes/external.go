@@ -4,7 +4,7 @@ import (
 	"strings"
 
 	"github.com/coredns/coredns/plugin/etcd/msg"
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
 
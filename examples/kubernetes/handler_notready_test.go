@@ -0,0 +1,275 @@
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+)
+
+// APIConnNotReadyTest serves services with not ready endpoints, which are published by the services named *pub.
+type APIConnNotReadyTest struct{ APIConnServeTest }
+
+var notReadySvcIndex = map[string][]*object.Service{
+	"hdlspub.testns": {{Name: "hdlspub", Namespace: "testns", Type: api.ServiceTypeClusterIP, ClusterIP: api.ClusterIPNone,
+		PublishNotReadyAddresses: true}},
+	"hdls.testns": {{Name: "hdls", Namespace: "testns", Type: api.ServiceTypeClusterIP, ClusterIP: api.ClusterIPNone}},
+	"svcpub.testns": {{Name: "svcpub", Namespace: "testns", Type: api.ServiceTypeClusterIP, ClusterIP: "10.0.1.1",
+		Ports: []api.ServicePort{{Name: "http", Protocol: "tcp", Port: 80}}, PublishNotReadyAddresses: true}},
+	"svc.testns": {{Name: "svc", Namespace: "testns", Type: api.ServiceTypeClusterIP, ClusterIP: "10.0.1.2",
+		Ports: []api.ServicePort{{Name: "http", Protocol: "tcp", Port: 80}}}},
+}
+
+var notReadyEpsIndex = map[string][]*object.Endpoints{
+	"hdlspub.testns": {{Name: "hdlspub", Namespace: "testns", Subsets: []object.EndpointSubset{{
+		Addresses:         []object.EndpointAddress{{IP: "172.0.1.1", Hostname: "ready"}},
+		NotReadyAddresses: []object.EndpointAddress{{IP: "172.0.1.2", Hostname: "notready"}},
+		Ports:             []object.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+	}}}},
+	"hdls.testns": {{Name: "hdls", Namespace: "testns", Subsets: []object.EndpointSubset{{
+		Addresses:         []object.EndpointAddress{{IP: "172.0.2.1", Hostname: "ready"}},
+		NotReadyAddresses: []object.EndpointAddress{{IP: "172.0.2.2", Hostname: "notready"}},
+		Ports:             []object.EndpointPort{{Port: 80, Protocol: "tcp", Name: "http"}},
+	}}}},
+	// the cluster IP services only have not ready endpoints
+	"svcpub.testns": {{Name: "svcpub", Namespace: "testns", Subsets: []object.EndpointSubset{{
+		NotReadyAddresses: []object.EndpointAddress{{IP: "172.0.3.2"}},
+		Ports:             []object.EndpointPort{{Port: -1}},
+	}}}},
+	"svc.testns": {{Name: "svc", Namespace: "testns", Subsets: []object.EndpointSubset{{
+		NotReadyAddresses: []object.EndpointAddress{{IP: "172.0.4.2"}},
+		Ports:             []object.EndpointPort{{Port: -1}},
+	}}}},
+}
+
+func (APIConnNotReadyTest) SvcIndex(s string) []*object.Service { return notReadySvcIndex[s] }
+
+func (APIConnNotReadyTest) ServiceList() []*object.Service {
+	var svcs []*object.Service
+	for _, svc := range notReadySvcIndex {
+		svcs = append(svcs, svc...)
+	}
+	return svcs
+}
+
+func (APIConnNotReadyTest) EpIndex(s string) []*object.Endpoints { return notReadyEpsIndex[s] }
+
+func (APIConnNotReadyTest) EndpointsList() []*object.Endpoints {
+	var eps []*object.Endpoints
+	for _, ep := range notReadyEpsIndex {
+		eps = append(eps, ep...)
+	}
+	return eps
+}
+
+// EpIndexReverse returns the endpoints with ip, which are indexed by their ready and not ready addresses.
+func (APIConnNotReadyTest) EpIndexReverse(ip string) []*object.Endpoints {
+	var eps []*object.Endpoints
+	for _, ep := range notReadyEpsIndex {
+		for _, e := range ep {
+			for _, eps1 := range e.Subsets {
+				for _, a := range append(eps1.Addresses, eps1.NotReadyAddresses...) {
+					if a.IP == ip {
+						eps = append(eps, e)
+					}
+				}
+			}
+		}
+	}
+	return eps
+}
+
+var dnsNotReadyTestCases = []test.Case{
+	// Headless Service publishing not ready endpoints
+	{
+		Qname: "hdlspub.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.1"),
+			test.A("hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.2"),
+		},
+	},
+	{
+		Qname: "notready.hdlspub.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("notready.hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.2"),
+		},
+	},
+	// Headless Service not publishing not ready endpoints
+	{
+		Qname: "hdls.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("hdls.testns.svc.cluster.local.	5	IN	A	172.0.2.1"),
+		},
+	},
+	{
+		Qname: "notready.hdls.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// With "ignore empty_service", a Service is only empty if it does not publish its not ready endpoints
+	{
+		Qname: "svcpub.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("svcpub.testns.svc.cluster.local.	5	IN	A	10.0.1.1"),
+		},
+	},
+	{
+		Qname: "svc.testns.svc.cluster.local.", Qtype: dns.TypeA,
+		Rcode: dns.RcodeNameError,
+		Ns: []dns.RR{
+			test.SOA("cluster.local.	5	IN	SOA	ns.dns.cluster.local. hostmaster.cluster.local. 1499347823 7200 1800 86400 5"),
+		},
+	},
+}
+
+func TestServeDNSNotReady(t *testing.T) {
+	k := New([]string{"cluster.local."})
+	k.APIConn = &APIConnNotReadyTest{}
+	k.opts.ignoreEmptyService = true
+	k.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	ctx := context.TODO()
+
+	for i, tc := range dnsNotReadyTestCases {
+		r := tc.Msg()
+
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		_, err := k.ServeDNS(ctx, w, r)
+		if err != tc.Error {
+			t.Errorf("Test %d expected no error, got %v", i, err)
+			return
+		}
+		if tc.Error != nil {
+			continue
+		}
+
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d, got nil message and no error for %q", i, r.Question[0].Name)
+		}
+
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
+
+func TestKubernetesXFRNotReady(t *testing.T) {
+	k := New([]string{"cluster.local."})
+	k.APIConn = &APIConnNotReadyTest{}
+	k.TransferTo = []string{"10.240.0.1:53"}
+
+	w := dnstest.NewMultiRecorder(&test.ResponseWriter{})
+	dnsmsg := &dns.Msg{}
+	dnsmsg.SetAxfr(k.Zones[0])
+
+	if _, err := k.ServeDNS(context.TODO(), w, dnsmsg); err != nil {
+		t.Fatal(err)
+	}
+
+	testRRs := []dns.RR{
+		// the not ready endpoint of hdlspub is transferred, and weighs as much as the ready one
+		test.A("hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.1"),
+		test.A("ready.hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.1"),
+		test.SRV("_http._tcp.hdlspub.testns.svc.cluster.local.	5	IN	SRV	0 50 80 ready.hdlspub.testns.svc.cluster.local."),
+		test.A("hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.2"),
+		test.A("notready.hdlspub.testns.svc.cluster.local.	5	IN	A	172.0.1.2"),
+		test.SRV("_http._tcp.hdlspub.testns.svc.cluster.local.	5	IN	SRV	0 50 80 notready.hdlspub.testns.svc.cluster.local."),
+		// the not ready endpoint of hdls is not
+		test.A("hdls.testns.svc.cluster.local.	5	IN	A	172.0.2.1"),
+		test.A("ready.hdls.testns.svc.cluster.local.	5	IN	A	172.0.2.1"),
+		test.SRV("_http._tcp.hdls.testns.svc.cluster.local.	5	IN	SRV	0 100 80 ready.hdls.testns.svc.cluster.local."),
+		test.A("svcpub.testns.svc.cluster.local.	5	IN	A	10.0.1.1"),
+		test.SRV("svcpub.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svcpub.testns.svc.cluster.local."),
+		test.SRV("_http._tcp.svcpub.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svcpub.testns.svc.cluster.local."),
+		test.A("svc.testns.svc.cluster.local.	5	IN	A	10.0.1.2"),
+		test.SRV("svc.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svc.testns.svc.cluster.local."),
+		test.SRV("_http._tcp.svc.testns.svc.cluster.local.	5	IN	SRV	0 100 80 svc.testns.svc.cluster.local."),
+	}
+
+	gotRRs := []dns.RR{}
+	for _, resp := range w.Msgs {
+		for _, ans := range resp.Answer {
+			if ans.Header().Rrtype == dns.TypeSOA {
+				continue
+			}
+			gotRRs = append(gotRRs, ans)
+		}
+	}
+
+	if diff := difference(testRRs, gotRRs); len(diff) != 0 {
+		t.Errorf("Got back %d records that do not exist in test cases, should be 0:", len(diff))
+		for _, rec := range diff {
+			t.Errorf("%+v", rec)
+		}
+	}
+	if diff := difference(gotRRs, testRRs); len(diff) != 0 {
+		t.Errorf("Found %d records we're missing, should be 0:", len(diff))
+		for _, rec := range diff {
+			t.Errorf("%+v", rec)
+		}
+	}
+}
+
+func TestReverseNotReady(t *testing.T) {
+	k := New([]string{"cluster.local.", "172.in-addr.arpa."})
+	k.APIConn = &APIConnNotReadyTest{}
+
+	tests := []test.Case{
+		{
+			Qname: "1.1.0.172.in-addr.arpa.", Qtype: dns.TypePTR,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.PTR("1.1.0.172.in-addr.arpa.	5	IN	PTR	ready.hdlspub.testns.svc.cluster.local."),
+			},
+		},
+		// the not ready endpoint of a Service publishing it has a PTR record
+		{
+			Qname: "2.1.0.172.in-addr.arpa.", Qtype: dns.TypePTR,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.PTR("2.1.0.172.in-addr.arpa.	5	IN	PTR	notready.hdlspub.testns.svc.cluster.local."),
+			},
+		},
+		// the not ready endpoint of a Service not publishing it has none
+		{
+			Qname: "2.2.0.172.in-addr.arpa.", Qtype: dns.TypePTR,
+			Rcode: dns.RcodeNameError,
+			Ns: []dns.RR{
+				test.SOA("172.in-addr.arpa.	5	IN	SOA	ns.dns.172.in-addr.arpa. hostmaster.172.in-addr.arpa. 1502989566 7200 1800 86400 5"),
+			},
+		},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		r := tc.Msg()
+
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		_, err := k.ServeDNS(ctx, w, r)
+		if err != tc.Error {
+			t.Errorf("Test %d: expected no error, got %v", i, err)
+			return
+		}
+
+		resp := w.Msg
+		if resp == nil {
+			t.Fatalf("Test %d: got nil message and no error for: %s %d", i, r.Question[0].Name, r.Question[0].Qtype)
+		}
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Errorf("Test %d: %v", i, err)
+		}
+	}
+}
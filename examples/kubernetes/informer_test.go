@@ -3,18 +3,18 @@ package kubernetes
 import (
 	"testing"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 
 	api "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
-func TestDefaultProcessor(t *testing.T) {
-	pbuild := object.DefaultProcessor(object.ToService(true), nil)
+func TestProcessor(t *testing.T) {
 	reh := cache.ResourceEventHandlerFuncs{}
 	idx := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
-	processor := pbuild(idx, reh)
+	processor := k8sapi.NewProcessor(idx, k8sapi.ConvertFunc(object.ToService(true)), nil, reh)
 	testProcessor(t, processor, idx)
 }
 
@@ -7,7 +7,7 @@ import (
 	"net"
 	"strings"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
@@ -324,7 +324,7 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 			podsCount := 0
 			for _, ep := range endpointsListFunc() {
 				for _, eps := range ep.Subsets {
-					podsCount = podsCount + len(eps.Addresses)
+					podsCount = podsCount + len(eps.ServedAddresses(svc.PublishNotReadyAddresses))
 				}
 			}
 
@@ -344,7 +344,7 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 				}
 
 				for _, eps := range ep.Subsets {
-					for _, addr := range eps.Addresses {
+					for _, addr := range eps.ServedAddresses(svc.PublishNotReadyAddresses) {
 
 						// See comments in parse.go parseRequest about the endpoint handling.
 						if r.endpoint != "" {
@@ -390,10 +390,13 @@ func (k *Kubernetes) findServices(r recordRequest, zone string) (services []msg.
 
 			err = nil
 
-			s := msg.Service{Host: svc.ClusterIP, Port: int(p.Port), TTL: k.ttl}
-			s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
+			// A dual-stack service has a cluster IP per IP family, the A and AAAA queries each use their own.
+			for _, ip := range svc.ClusterIPList() {
+				s := msg.Service{Host: ip, Port: int(p.Port), TTL: k.ttl}
+				s.Key = strings.Join([]string{zonePath, Svc, svc.Namespace, svc.Name}, "/")
 
-			services = append(services, s)
+				services = append(services, s)
+			}
 		}
 	}
 	return services, err
@@ -6,8 +6,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
-	"k8s.io/client-go/tools/cache"
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	api "k8s.io/api/core/v1"
@@ -34,11 +34,11 @@ func TestDnsProgrammingLatency(t *testing.T) {
 	ctx := context.TODO()
 
 	informerFuncs := k.Informers()
-	epInformer := informerFuncs["endpoints"](ctx, client)
-	svcInformer := informerFuncs["service"](ctx, client)
+	epInformer := informerFuncs["endpoints"](ctx, client, k8sapi.InformerOptions{})
+	svcInformer := informerFuncs["service"](ctx, client, k8sapi.InformerOptions{})
 
-	dnsCon.epLister = epInformer.Lister.(cache.Indexer)
-	dnsCon.svcLister = svcInformer.Lister.(cache.Indexer)
+	dnsCon.SetLister("endpoints", epInformer.Lister)
+	dnsCon.SetLister("service", svcInformer.Lister)
 
 	durationSinceFunc = func(t time.Time) time.Duration {
 		return now.Sub(t)
@@ -4,7 +4,7 @@ import (
 	"net"
 	"strings"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/miekg/dns"
 	api "k8s.io/api/core/v1"
 )
@@ -29,6 +29,10 @@ func (k *Kubernetes) nsAddrs(external bool, zone string) []dns.RR {
 		for _, endpoint := range endpoints {
 			svcs := k.APIConn.SvcIndex(object.ServiceKey(endpoint.Name, endpoint.Namespace))
 			for _, svc := range svcs {
+				// the endpoints are also indexed by their not ready addresses
+				if !servesIP(endpoint, svc.PublishNotReadyAddresses, localIP.String()) {
+					continue
+				}
 				if external {
 					svcName := strings.Join([]string{svc.Name, svc.Namespace, zone}, ".")
 					for _, exIP := range svc.ExternalIPs {
@@ -47,8 +51,10 @@ func (k *Kubernetes) nsAddrs(external bool, zone string) []dns.RR {
 						}
 					}
 				} else {
-					svcNames = append(svcNames, svcName)
-					svcIPs = append(svcIPs, net.ParseIP(svc.ClusterIP))
+					for _, ip := range svc.ClusterIPList() {
+						svcNames = append(svcNames, svcName)
+						svcIPs = append(svcIPs, net.ParseIP(ip))
+					}
 				}
 			}
 		}
@@ -5,7 +5,7 @@ import (
 	"net"
 	"testing"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"k8s.io/client-go/tools/cache"
 
 	"github.com/miekg/dns"
@@ -4,6 +4,7 @@ import (
 	"context"
 	"strings"
 
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
@@ -27,7 +28,8 @@ func (k *Kubernetes) Reverse(ctx context.Context, state request.Request, exact b
 }
 
 // serviceRecordForIP gets a service record with a cluster ip matching the ip argument
-// If a service cluster ip does not match, it checks all endpoints
+// If a service cluster ip does not match, it checks all endpoints, including those not ready of the services
+// publishing them
 func (k *Kubernetes) serviceRecordForIP(ip, name string) []msg.Service {
 	// First check services with cluster ips
 	for _, service := range k.APIConn.SvcIndexReverse(ip) {
@@ -43,8 +45,9 @@ func (k *Kubernetes) serviceRecordForIP(ip, name string) []msg.Service {
 		if len(k.Namespaces) > 0 && !k.namespaceExposed(ep.Namespace) {
 			continue
 		}
+		publishNotReady := k.publishesNotReady(ep)
 		for _, eps := range ep.Subsets {
-			for _, addr := range eps.Addresses {
+			for _, addr := range eps.ServedAddresses(publishNotReady) {
 				if addr.IP == ip {
 					domain := strings.Join([]string{endpointHostname(addr, k.endpointNameMode), ep.Name, ep.Namespace, Svc, k.primaryZone()}, ".")
 					svcs = append(svcs, msg.Service{Host: domain, TTL: k.ttl})
@@ -54,3 +57,26 @@ func (k *Kubernetes) serviceRecordForIP(ip, name string) []msg.Service {
 	}
 	return svcs
 }
+
+// publishesNotReady returns true if a service of the endpoints publishes its not ready addresses.
+func (k *Kubernetes) publishesNotReady(ep *object.Endpoints) bool {
+	for _, svc := range k.APIConn.SvcIndex(object.ServiceKey(ep.Name, ep.Namespace)) {
+		if svc.PublishNotReadyAddresses {
+			return true
+		}
+	}
+	return false
+}
+
+// servesIP returns true if ip is an address of the endpoints that is served, as a ready address or as a not ready
+// address of a service publishing them.
+func servesIP(ep *object.Endpoints, publishNotReady bool, ip string) bool {
+	for _, eps := range ep.Subsets {
+		for _, addr := range eps.ServedAddresses(publishNotReady) {
+			if addr.IP == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
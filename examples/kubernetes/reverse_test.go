@@ -4,7 +4,7 @@ import (
 	"context"
 	"testing"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/test"
 	"k8s.io/client-go/tools/cache"
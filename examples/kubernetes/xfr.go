@@ -108,13 +108,15 @@ func (k *Kubernetes) transfer(c chan dns.RR, zone string) {
 		svcBase := []string{zonePath, Svc, svc.Namespace, svc.Name}
 		switch svc.Type {
 		case api.ServiceTypeClusterIP, api.ServiceTypeNodePort, api.ServiceTypeLoadBalancer:
-			clusterIP := net.ParseIP(svc.ClusterIP)
-			if clusterIP != nil {
-				s := msg.Service{Host: svc.ClusterIP, TTL: k.ttl}
-				s.Key = strings.Join(svcBase, "/")
+			if ips := svc.ClusterIPList(); len(ips) > 0 {
+				var host string
+				for _, ip := range ips {
+					s := msg.Service{Host: ip, TTL: k.ttl}
+					s.Key = strings.Join(svcBase, "/")
 
-				// Change host from IP to Name for SRV records
-				host := emitAddressRecord(c, s)
+					// Change host from IP to Name for SRV records
+					host = emitAddressRecord(c, s)
+				}
 
 				for _, p := range svc.Ports {
 					s := msg.Service{Host: host, Port: int(p.Port), TTL: k.ttl}
@@ -147,8 +149,9 @@ func (k *Kubernetes) transfer(c chan dns.RR, zone string) {
 				}
 
 				for _, eps := range ep.Subsets {
-					srvWeight := calcSRVWeight(len(eps.Addresses))
-					for _, addr := range eps.Addresses {
+					addrs := eps.ServedAddresses(svc.PublishNotReadyAddresses)
+					srvWeight := calcSRVWeight(len(addrs))
+					for _, addr := range addrs {
 						s := msg.Service{Host: addr.IP, TTL: k.ttl}
 						s.Key = strings.Join(svcBase, "/")
 						// We don't need to change the msg.Service host from IP to Name yet
@@ -4,21 +4,41 @@ import (
 	"errors"
 
 	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+	"github.com/chrisohaver/k8s_api/k8s_api/listers"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
 	"k8s.io/client-go/tools/cache"
 )
 
 func (p *PodNames) Informers() map[string]k8sapi.InformerFunc { return nil }
 
+// Indexers implements the k8sapi.IndexProvider interface.
+func (p *PodNames) Indexers() map[string]cache.Indexers {
+	return map[string]cache.Indexers{"pod": {podIPIndex: podIPIndexFunc}}
+}
+
 func (p *PodNames) SetIndexer(name string, lister cache.KeyListerGetter) error {
 	if name != "pod" {
 		return nil
 	}
-	pidx, ok := lister.(cache.Indexer)
-	if !ok {
-		return errors.New("unexpected lister type")
+	pods, err := listers.NewPodLister(lister)
+	if err != nil {
+		return err
 	}
-	p.podIndexer = pidx
+	p.podLister = pods
 	return nil
 }
 
 func (p *PodNames) SetHasSynced(syncedFunc k8sapi.HasSyncedFunc) {}
+
+// SetInformerSynced implements the k8sapi.InformerSyncer interface.
+func (p *PodNames) SetInformerSynced(syncedFunc k8sapi.InformerSyncedFunc) {
+	p.podSynced = func() bool { return syncedFunc("pod") }
+}
+
+func podIPIndexFunc(obj interface{}) ([]string, error) {
+	p, ok := obj.(*object.Pod)
+	if !ok {
+		return nil, errors.New("unexpected indexer item type")
+	}
+	return []string{p.PodIP}, nil
+}
@@ -2,11 +2,9 @@ package podnames
 
 import (
 	"context"
-	"errors"
 	"net"
 	"strings"
 
-	"github.com/chrisohaver/k8s_api/examples/kubernetes/object"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
@@ -28,17 +26,13 @@ func (p PodNames) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	if dnsutil.IsReverse(qname) > 0 {
 		// handle reverse
 		ip := dnsutil.ExtractAddressFromReverse(state.Name())
-		objs, err := p.podIndexer.ByIndex("PodIP", ip) // expose constant from kubernetes package?
+		pods, err := p.podLister.ByIndex(podIPIndex, ip)
 		if err != nil {
 			return dns.RcodeServerFailure, err
 		}
 		m := &dns.Msg{}
 		m.SetReply(r)
-		for _, o := range objs {
-			pod, ok := o.(*object.Pod)
-			if !ok {
-				continue
-			}
+		for _, pod := range pods {
 			m.Answer = append(m.Answer, &dns.PTR{
 				Hdr: dns.RR_Header{
 					Name:   qname,
@@ -62,17 +56,17 @@ func (p PodNames) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	if len(segs) < 2 {
 		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
 	}
-	item, exists, err := p.podIndexer.GetByKey(segs[1] + "/" + segs[0])
+	pod, exists, err := p.podLister.Get(segs[1], segs[0])
 	if err != nil {
 		return dns.RcodeServerFailure, err
 	}
 	if !exists {
+		if p.podSynced != nil && !p.podSynced() {
+			// If we haven't synchronized with the kubernetes cluster, return server failure
+			return dns.RcodeServerFailure, nil
+		}
 		return plugin.NextOrFailure(p.Name(), p.Next, ctx, w, r)
 	}
-	pod, ok := item.(*object.Pod)
-	if !ok {
-		return dns.RcodeServerFailure, errors.New("unexpected indexer item type")
-	}
 	ip := net.ParseIP(pod.PodIP)
 
 	// construct the reply
@@ -1,15 +1,20 @@
 package podnames
 
 import (
+	"github.com/chrisohaver/k8s_api/k8s_api/listers"
 	"github.com/coredns/coredns/plugin"
-	"k8s.io/client-go/tools/cache"
 )
 
-const pluginName = "podnames"
+const (
+	pluginName = "podnames"
+	// podIPIndex is the index podnames adds to the "pod" informer
+	podIPIndex = "podnames.PodIP"
+)
 
 type PodNames struct {
 	Next       plugin.Handler
 	Zones      []string
-	podIndexer cache.Indexer
+	podLister  *listers.PodLister
+	podSynced  func() bool
 	ttl uint32
 }
@@ -3,8 +3,12 @@ module github.com/chrisohaver/k8s_api
 go 1.13
 
 require (
-	github.com/coredns/coredns v1.7.1 // indirect
-	k8s.io/api v0.19.1
-	k8s.io/apimachinery v0.19.1
-	k8s.io/client-go v0.19.1
+	github.com/caddyserver/caddy v1.0.5
+	github.com/coredns/coredns v1.7.1
+	github.com/miekg/dns v1.1.31
+	github.com/prometheus/client_golang v1.7.1
+	k8s.io/api v0.20.15
+	k8s.io/apimachinery v0.20.15
+	k8s.io/client-go v0.20.15
+	k8s.io/klog v1.0.0
 )
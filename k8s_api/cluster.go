@@ -0,0 +1,104 @@
+package k8sapi
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// InformerName returns the name under which an Informer is bound to the named cluster, i.e. "cluster/informer".
+// Informers whose name has no cluster prefix are bound to the default connection.
+func InformerName(cluster, informer string) string {
+	if cluster == "" {
+		return informer
+	}
+	return cluster + "/" + informer
+}
+
+// splitInformerName returns the cluster and Informer names of an Informer name built by InformerName.
+func splitInformerName(name string) (cluster, informer string) {
+	i := strings.Index(name, "/")
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+1:]
+}
+
+// clusterControl runs the apiControls of several clusters as a single apiController. Informers are named
+// "cluster/informer", except for Informers of the default cluster, which are named by their Informer name only.
+type clusterControl map[string]*apiControl
+
+var _ apiController = clusterControl{}
+
+// Run runs the controllers of all clusters until they are stopped.
+func (cc clusterControl) Run() {
+	var wg sync.WaitGroup
+	for _, c := range cc {
+		wg.Add(1)
+		go func(c *apiControl) {
+			defer wg.Done()
+			c.Run()
+		}(c)
+	}
+	wg.Wait()
+}
+
+// Stop stops the controllers of all clusters.
+func (cc clusterControl) Stop() error {
+	var err error
+	for _, c := range cc {
+		if e := c.Stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// HasSynced returns true if the Informers of all clusters have synced.
+func (cc clusterControl) HasSynced() bool {
+	for _, c := range cc {
+		if !c.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// InformerSynced returns true if the named Informer exists and has synced.
+func (cc clusterControl) InformerSynced(name string) bool {
+	cluster, informer := splitInformerName(name)
+	c, ok := cc[cluster]
+	return ok && c.InformerSynced(informer)
+}
+
+// Health returns the Health of the named Informer, and false if it does not exist.
+func (cc clusterControl) Health(name string) (Health, bool) {
+	cluster, informer := splitInformerName(name)
+	c, ok := cc[cluster]
+	if !ok {
+		return Health{}, false
+	}
+	return c.Health(informer)
+}
+
+// Unsynced returns the sorted names of the Informers that have not synced.
+func (cc clusterControl) Unsynced() []string {
+	var names []string
+	for cluster, c := range cc {
+		for _, n := range c.Unsynced() {
+			names = append(names, InformerName(cluster, n))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// informersSynced returns true if all named Informers have synced.
+func (cc clusterControl) informersSynced(names []string) bool {
+	for _, n := range names {
+		if !cc.InformerSynced(n) {
+			return false
+		}
+	}
+	return true
+}
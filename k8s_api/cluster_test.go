@@ -0,0 +1,123 @@
+package k8sapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestParseClusters(t *testing.T) {
+	tests := []struct {
+		input            string // Corefile data as string
+		shouldErr        bool
+		expectedClusters map[string]string // expected endpoint per cluster
+	}{
+		{`k8s_api`, false, map[string]string{}},
+		{`k8s_api {
+			endpoint http://localhost:8080
+			cluster east {
+				endpoint https://east:6443
+				tls cert key cacert
+			}
+			cluster west {
+				endpoint https://west:6443
+			}
+			namespaces ns1
+		}`, false, map[string]string{"east": "https://east:6443", "west": "https://west:6443"}},
+		{`k8s_api {
+			cluster east {
+				kubeconfig file context
+			}
+		}`, false, map[string]string{"east": ""}},
+		// duplicate cluster
+		{`k8s_api {
+			cluster east {
+			}
+			cluster east {
+			}
+		}`, true, nil},
+		// invalid cluster name
+		{`k8s_api {
+			cluster a/b {
+			}
+		}`, true, nil},
+		// missing block
+		{`k8s_api {
+			cluster east
+		}`, true, nil},
+		// non connection property
+		{`k8s_api {
+			cluster east {
+				namespaces ns1
+			}
+		}`, true, nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+
+		got := make(map[string]string)
+		for n, conn := range k.clusters {
+			got[n] = conn.APIServer
+		}
+		if !reflect.DeepEqual(got, tc.expectedClusters) {
+			t.Errorf("Test %d: Expected clusters %v, got %v", i, tc.expectedClusters, got)
+		}
+	}
+}
+
+func TestSplitInformerName(t *testing.T) {
+	for _, name := range []string{"service", "east/service"} {
+		cluster, informer := splitInformerName(name)
+		if n := InformerName(cluster, informer); n != name {
+			t.Errorf("Expected %q, got %q", name, n)
+		}
+	}
+}
+
+type syncedController bool
+
+func (s syncedController) Run(<-chan struct{}) {}
+func (s syncedController) HasSynced() bool     { return bool(s) }
+func (s syncedController) LastSyncResourceVersion() string {
+	return ""
+}
+
+func TestClusterControlSynced(t *testing.T) {
+	cc := clusterControl{
+		"": {Informers: map[string]*Informer{
+			"service": {Controller: syncedController(true)},
+		}},
+		"east": {cluster: "east", Informers: map[string]*Informer{
+			"service": {Controller: syncedController(true)},
+			"pod":     {Controller: syncedController(false)},
+		}},
+	}
+	if !cc.InformerSynced("service") || !cc.InformerSynced("east/service") {
+		t.Error("Expected service informers to be synced")
+	}
+	if cc.InformerSynced("east/pod") || cc.InformerSynced("west/service") {
+		t.Error("Expected east/pod and west/service not to be synced")
+	}
+	if cc.HasSynced() {
+		t.Error("Expected clusters not to be synced")
+	}
+	if u := cc.Unsynced(); !reflect.DeepEqual(u, []string{"east/pod"}) {
+		t.Errorf("Expected [east/pod] unsynced, got %v", u)
+	}
+}
+
+var _ cache.Controller = syncedController(true)
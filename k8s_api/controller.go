@@ -1,9 +1,14 @@
 package k8sapi
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
+	"time"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
@@ -11,20 +16,70 @@ import (
 type apiController interface {
 	Run()
 	HasSynced() bool
+	InformerSynced(name string) bool
+	Unsynced() []string
+	Health(name string) (Health, bool)
 	Stop() error
 }
 
 type apiControl struct {
-	client kubernetes.Interface
+	// cluster is the name of the cluster the apiControl connects to, empty for the default connection.
+	cluster       string
+	client        kubernetes.Interface
+	dynamicClient dynamic.Interface
 
+	// id is the control label of the metrics of its Informers, set by the informers collector.
+	id string
+
+	// transport reloads the connection settings when the files they are read from change.
+	transport *reloadingTransport
+
+	// source serves the objects of manifests to the clients, when the apiControl is not connected to an API server.
+	source *manifestSource
+
+	// snapshots writes snapshots of the objects of the Informers, if enabled.
+	snapshots *snapshotSet
+
+	// lock guards Informers, objTypes, routers, health, ctx and build, which are replaced when a stopped apiControl
+	// is started again.
+	lock      sync.RWMutex
 	Informers map[string]*Informer
 
-	// stopLock is used to enforce only a single call to Stop is active.
-	// Needed because we allow stopping through an http endpoint and
-	// allowing concurrent stoppers leads to stack traces.
+	// objTypes are the declared object types of the Informers, passed to the plugins with their stores.
+	objTypes map[string]reflect.Type
+
+	// routers route the events of each Informer to the plugins using it.
+	routers map[string]*eventRouter
+
+	// health tracks the connection of each Informer to the API, and maxStaleness is how long an Informer may
+	// fail to reach the API before it is stale.
+	health       map[string]*informerHealth
+	maxStaleness time.Duration
+
+	// ctx is the context passed to the Informer functions, and cancel cancels it when the apiControl stops. build
+	// holds the values the Informer functions share through InformerOptions.BuildValue.
+	ctx    context.Context
+	cancel context.CancelFunc
+	build  *buildValues
+
+	// rebuild builds the Informers again and hands their stores to the plugins using them, as an Informer cannot
+	// run again once stopped. It is called when a stopped apiControl is started again.
+	rebuild func() error
+
+	// key identifies the configuration of the apiControl, and reuse holds the state needed to hand it
+	// over to a new k8s_api instance after a Corefile reload. Both are guarded by the reusable registry.
+	key   string
+	reuse reuseState
+
+	// stopLock serializes starting and stopping the apiControl, which may be stopped concurrently, e.g. through
+	// an http endpoint. running is set while it runs. stopped is set by Stop, so that a Run that has not started
+	// the apiControl yet does not start it after it was stopped; restart clears it. done is closed when the
+	// goroutines of the last run have returned.
 	stopLock sync.Mutex
-	shutdown bool
+	running  bool
+	stopped  bool
 	stopCh   chan struct{}
+	done     chan struct{}
 
 	zones            []string
 	endpointNameMode bool
@@ -35,35 +90,169 @@ type Informer struct {
 	Lister cache.KeyListerGetter
 }
 
-// Stop stops the  controller.
+// stopTimeout is how long Stop waits for the goroutines of the Informers to return.
+const stopTimeout = 5 * time.Second
+
+// Stop stops the controller, and waits up to stopTimeout for its Informers to stop. A stopped controller is only
+// started again by restart, so stopping a controller that is not running yet keeps it from starting.
 func (dns *apiControl) Stop() error {
 	dns.stopLock.Lock()
 	defer dns.stopLock.Unlock()
-
-	// Only try draining the workqueue if we haven't already.
-	if !dns.shutdown {
-		close(dns.stopCh)
-		dns.shutdown = true
+	dns.stopped = true
+	if !dns.running {
 		return nil
 	}
+	dns.running = false
+	close(dns.stopCh)
+	if dns.cancel != nil {
+		// abort the lists and watches in flight
+		dns.cancel()
+	}
+	dns.lock.RLock()
+	for _, r := range dns.routers {
+		if _, f := r.handlers(); f != nil {
+			f.stop()
+		}
+	}
+	dns.lock.RUnlock()
+	informers.remove(dns)
 
-	return fmt.Errorf("shutdown already in progress")
+	var err error
+	select {
+	case <-dns.done:
+	case <-time.After(stopTimeout):
+		err = fmt.Errorf("informers of cluster %q not stopped after %v", dns.cluster, stopTimeout)
+	}
+	if dns.snapshots != nil {
+		// keep the latest objects for the next start
+		dns.snapshots.save()
+	}
+	return err
 }
 
-// Run starts the controller.
+// Run starts the controller, and blocks until it is stopped. An apiControl handed over to a new k8s_api instance
+// is already running, so Run then only waits for it to stop. Run returns at once if the controller was stopped.
 func (dns *apiControl) Run() {
+	stopCh, err := dns.start()
+	if err != nil {
+		log.Errorf("Failed to start informers of cluster %q: %v", dns.cluster, err)
+		return
+	}
+	<-stopCh
+}
+
+// restart starts a stopped controller again, and blocks until it is stopped.
+func (dns *apiControl) restart() {
+	dns.stopLock.Lock()
+	dns.stopped = false
+	dns.stopLock.Unlock()
+	dns.Run()
+}
+
+// start starts the controller, unless it is running or was stopped, and returns the channel closed when it stops.
+func (dns *apiControl) start() (<-chan struct{}, error) {
+	dns.stopLock.Lock()
+	defer dns.stopLock.Unlock()
+	if dns.running {
+		return dns.stopCh, nil
+	}
+	if dns.stopped {
+		stopCh := make(chan struct{})
+		close(stopCh)
+		return stopCh, nil
+	}
+	if dns.done != nil && dns.rebuild != nil {
+		if err := dns.rebuild(); err != nil {
+			return nil, err
+		}
+	}
+	stopCh := make(chan struct{})
+	var wg sync.WaitGroup
+	run := func(f func(<-chan struct{})) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(stopCh)
+		}()
+	}
+	if dns.transport != nil {
+		run(dns.transport.run)
+	}
+	if dns.source != nil {
+		run(dns.source.run)
+	}
+	if dns.snapshots != nil {
+		run(dns.snapshots.run)
+	}
+	dns.lock.RLock()
+	for _, r := range dns.routers {
+		if _, f := r.handlers(); f != nil {
+			f.start()
+		}
+	}
 	for _, w := range dns.Informers {
-		go w.Controller.Run(dns.stopCh)
+		run(w.Controller.Run)
 	}
-	<-dns.stopCh
+	dns.lock.RUnlock()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	dns.stopCh, dns.done = stopCh, done
+	dns.running = true
+	informers.add(dns)
+	return stopCh, nil
+}
+
+// setRebuild sets the function building the Informers again when the controller is started after it stopped.
+func (dns *apiControl) setRebuild(f func() error) {
+	dns.stopLock.Lock()
+	defer dns.stopLock.Unlock()
+	dns.rebuild = f
 }
 
 // HasSynced calls on all controllers.
 func (dns *apiControl) HasSynced() bool {
+	dns.lock.RLock()
+	defer dns.lock.RUnlock()
 	for _, w := range dns.Informers {
 		if !w.Controller.HasSynced() {
 			return false
 		}
 	}
 	return true
-}
\ No newline at end of file
+}
+
+// InformerSynced returns true if the named Informer exists and has synced.
+func (dns *apiControl) InformerSynced(name string) bool {
+	dns.lock.RLock()
+	defer dns.lock.RUnlock()
+	w, ok := dns.Informers[name]
+	return ok && w.Controller.HasSynced()
+}
+
+// Health returns the Health of the named Informer, and false if it does not exist.
+func (dns *apiControl) Health(name string) (Health, bool) {
+	dns.lock.RLock()
+	h, ok := dns.health[name]
+	dns.lock.RUnlock()
+	if !ok {
+		return Health{}, false
+	}
+	return h.health(time.Now(), dns.maxStaleness), true
+}
+
+// Unsynced returns the sorted names of the Informers that have not synced.
+func (dns *apiControl) Unsynced() []string {
+	dns.lock.RLock()
+	defer dns.lock.RUnlock()
+	var names []string
+	for n, w := range dns.Informers {
+		if !w.Controller.HasSynced() {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
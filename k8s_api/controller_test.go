@@ -0,0 +1,369 @@
+package k8sapi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// runningController is a cache.Controller counting its runs, and the runs that have not returned.
+type runningController struct {
+	runs, active int32
+}
+
+func (c *runningController) Run(stopCh <-chan struct{}) {
+	atomic.AddInt32(&c.runs, 1)
+	atomic.AddInt32(&c.active, 1)
+	defer atomic.AddInt32(&c.active, -1)
+	<-stopCh
+}
+func (c *runningController) HasSynced() bool                 { return true }
+func (c *runningController) LastSyncResourceVersion() string { return "" }
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAPIControlStopStart(t *testing.T) {
+	ctl := &runningController{}
+	dns := &apiControl{Informers: map[string]*Informer{"service": {Controller: ctl}}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dns.Run()
+	}()
+	waitFor(t, "informer to run", func() bool { return atomic.LoadInt32(&ctl.active) == 1 })
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Stop returns once the informers have returned
+	if n := atomic.LoadInt32(&ctl.active); n != 0 {
+		t.Errorf("Expected stopped informer, got %d running", n)
+	}
+	if err := dns.Stop(); err != nil {
+		t.Errorf("Expected repeated stop to do nothing, got %v", err)
+	}
+	wg.Wait()
+
+	// a stopped controller is rebuilt and runs again
+	var rebuilds int32
+	dns.setRebuild(func() error {
+		atomic.AddInt32(&rebuilds, 1)
+		return nil
+	})
+	// a stopped controller is not started by Run
+	dns.Run()
+	if n := atomic.LoadInt32(&ctl.runs); n != 1 {
+		t.Errorf("Expected 1 run, got %d", n)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		dns.restart()
+	}()
+	waitFor(t, "informer to run again", func() bool { return atomic.LoadInt32(&ctl.runs) == 2 })
+	if atomic.LoadInt32(&rebuilds) != 1 {
+		t.Errorf("Expected 1 rebuild, got %d", atomic.LoadInt32(&rebuilds))
+	}
+	// a running controller is not started again
+	go dns.Run()
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&ctl.runs); n != 2 {
+		t.Errorf("Expected 2 runs, got %d", n)
+	}
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wg.Wait()
+}
+
+func TestAPIControlStopBeforeRun(t *testing.T) {
+	ctl := &runningController{}
+	dns := &apiControl{Informers: map[string]*Informer{"service": {Controller: ctl}}}
+
+	// a controller stopped before its Run started is not started by it
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dns.Run()
+	if n := atomic.LoadInt32(&ctl.runs); n != 0 {
+		t.Errorf("Expected no runs, got %d", n)
+	}
+	if err := dns.Stop(); err != nil {
+		t.Errorf("Expected stop of a stopped controller to do nothing, got %v", err)
+	}
+}
+
+// listerWatcher is an APIWatcher keeping the stores passed to SetIndexer.
+type listerWatcher struct {
+	testWatcher
+
+	lock    sync.Mutex
+	listers map[string]cache.KeyListerGetter
+}
+
+func (w *listerWatcher) SetIndexer(name string, l cache.KeyListerGetter) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.listers[name] = l
+	return nil
+}
+
+func (w *listerWatcher) lister(name string) cache.KeyListerGetter {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.listers[name]
+}
+
+func serviceInformer(ctx context.Context, client kubernetes.Interface, o InformerOptions) *Informer {
+	lw := o.ListerWatcher(func(ns string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options meta.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Services(ns).List(ctx, options)
+			},
+			WatchFunc: func(options meta.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Services(ns).Watch(ctx, options)
+			},
+		}
+	})
+	store, controller := cache.NewInformer(lw, &api.Service{}, 0, o.EventHandler(nil))
+	return &Informer{Controller: controller, Lister: store}
+}
+
+func TestAPIControlRebuild(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices})
+
+	w := &listerWatcher{
+		testWatcher: testWatcher{name: "first", informers: map[string]InformerFunc{"service": serviceInformer}},
+		listers:     make(map[string]cache.KeyListerGetter),
+	}
+	plugins := []plugin.Handler{w}
+	regs, err := registerInformers(plugins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	k := New(nil)
+	k.manifests = dir
+	dns, err := k.buildAPIControl("", &k.Connection, regs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dns.setRebuild(k.rebuilder(dns, &k.Connection, regs, plugins))
+	if err := setIndexers(w, dns); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	go dns.Run()
+	waitFor(t, "informer to sync", dns.HasSynced)
+	first := w.lister("service")
+	if len(first.ListKeys()) != 2 {
+		t.Errorf("Expected 2 services, got %v", first.ListKeys())
+	}
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the restarted Informer has a new store, handed to the plugin
+	go dns.restart()
+	defer dns.Stop()
+	waitFor(t, "new store", func() bool { return w.lister("service") != first })
+	waitFor(t, "informer to sync again", dns.HasSynced)
+	second := w.lister("service")
+	if len(second.ListKeys()) != 2 {
+		t.Errorf("Expected 2 services, got %v", second.ListKeys())
+	}
+}
+
+func TestInformerContext(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices})
+
+	var lock sync.Mutex
+	var ctxs []context.Context
+	plugins := []plugin.Handler{testWatcher{name: "first", informers: map[string]InformerFunc{
+		"east/service": func(ctx context.Context, _ kubernetes.Interface, _ InformerOptions) *Informer {
+			lock.Lock()
+			defer lock.Unlock()
+			ctxs = append(ctxs, ctx)
+			return &Informer{Controller: &runningController{}, Lister: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+		},
+	}}}
+	regs, err := registerInformers(plugins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	k := New(nil)
+	conn := &Connection{manifests: dir}
+	k.clusters["east"] = conn
+	dns, err := k.buildAPIControl("east", conn, regs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dns.setRebuild(k.rebuilder(dns, conn, regs, plugins))
+	if cluster, informer, ok := InformerFromContext(ctxs[0]); !ok || cluster != "east" || informer != "service" {
+		t.Errorf("Expected informer east/service in context, got %q/%q (%v)", cluster, informer, ok)
+	}
+	if _, _, ok := InformerFromContext(context.Background()); ok {
+		t.Error("Expected no informer in background context")
+	}
+
+	go dns.Run()
+	waitFor(t, "controller to run", func() bool {
+		dns.stopLock.Lock()
+		defer dns.stopLock.Unlock()
+		return dns.running
+	})
+	if ctxs[0].Err() != nil {
+		t.Fatal("Expected context not to be cancelled while running")
+	}
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ctxs[0].Err() != context.Canceled {
+		t.Errorf("Expected context to be cancelled after stop, got %v", ctxs[0].Err())
+	}
+
+	// a restart builds the Informer with a new context
+	go dns.restart()
+	defer dns.Stop()
+	waitFor(t, "informer to be built again", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(ctxs) == 2
+	})
+	lock.Lock()
+	defer lock.Unlock()
+	if ctxs[1].Err() != nil {
+		t.Error("Expected new context not to be cancelled")
+	}
+}
+
+func TestBuildValue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices})
+
+	type key struct{}
+	var lock sync.Mutex
+	var builds int
+	var values []interface{}
+	informer := func(_ context.Context, _ kubernetes.Interface, o InformerOptions) *Informer {
+		v := o.BuildValue(key{}, func() interface{} {
+			builds++
+			return builds
+		})
+		lock.Lock()
+		defer lock.Unlock()
+		values = append(values, v)
+		return &Informer{Controller: &runningController{}, Lister: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	}
+	plugins := []plugin.Handler{testWatcher{name: "first", informers: map[string]InformerFunc{
+		"service": informer,
+		"pod":     informer,
+	}}}
+	regs, err := registerInformers(plugins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	k := New(nil)
+	k.manifests = dir
+	dns, err := k.buildAPIControl("", &k.Connection, regs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	dns.setRebuild(k.rebuilder(dns, &k.Connection, regs, plugins))
+
+	// the Informers of a build, and those handed over to a new instance, share the value
+	if err := k.handOver(dns, regs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lock.Lock()
+	if !reflect.DeepEqual(values, []interface{}{1, 1, 1, 1}) {
+		t.Errorf("Expected the value of the first build, got %v", values)
+	}
+	values = nil
+	lock.Unlock()
+
+	// a restart builds the Informers with a new value
+	go dns.Run()
+	waitFor(t, "controller to run", func() bool {
+		dns.stopLock.Lock()
+		defer dns.stopLock.Unlock()
+		return dns.running
+	})
+	if err := dns.Stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go dns.restart()
+	defer dns.Stop()
+	waitFor(t, "informers to be built again", func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(values) == 2
+	})
+	lock.Lock()
+	defer lock.Unlock()
+	if !reflect.DeepEqual(values, []interface{}{2, 2}) {
+		t.Errorf("Expected the value of the second build, got %v", values)
+	}
+
+	// without a build, the value is not shared
+	if v := (InformerOptions{}).BuildValue(key{}, func() interface{} { return 0 }); v != 0 {
+		t.Errorf("Expected a new value, got %v", v)
+	}
+}
+
+func TestSetIndexersTyped(t *testing.T) {
+	w := &listerWatcher{listers: make(map[string]cache.KeyListerGetter)}
+	dns := &apiControl{
+		cluster: "east",
+		Informers: map[string]*Informer{
+			"service": {Lister: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+			"pod":     {Lister: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})},
+		},
+		objTypes: map[string]reflect.Type{"service": reflect.TypeOf(&api.Service{})},
+	}
+	if err := setIndexers(w, dns); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	typed, ok := w.lister("east/service").(TypedLister)
+	if !ok || typed.ObjectType() != reflect.TypeOf(&api.Service{}) {
+		t.Errorf("Expected east/service store to be typed as *v1.Service, got %T", w.lister("east/service"))
+	}
+	if _, ok := w.lister("east/pod").(TypedLister); ok {
+		t.Error("Expected east/pod store without declared type not to be typed")
+	}
+}
@@ -0,0 +1,184 @@
+package k8sapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/reuseport"
+	"k8s.io/client-go/tools/cache"
+)
+
+// debugServer serves the state of the Informers of a k8s_api instance over HTTP, so that one can check which
+// objects CoreDNS sees:
+//
+//	/informers lists the Informers, with the plugins using them, their sync status, object count and health.
+//	/objects?informer=NAME[&namespace=NS][&name=NAME] dumps the objects in the store of an Informer.
+type debugServer struct {
+	addr string
+	k    *KubeAPI
+
+	lock sync.Mutex
+	ln   net.Listener
+}
+
+// debugInformer is the state of an Informer served by /informers.
+type debugInformer struct {
+	Name        string   `json:"name"`
+	Cluster     string   `json:"cluster,omitempty"`
+	Owner       string   `json:"owner"`
+	Consumers   []string `json:"consumers"`
+	Subscribers []string `json:"subscribers,omitempty"`
+	Type        string   `json:"type,omitempty"`
+	Synced      bool     `json:"synced"`
+	Objects     int      `json:"objects"`
+
+	Health *debugHealth `json:"health,omitempty"`
+}
+
+// debugHealth is the Health of an Informer served by /informers.
+type debugHealth struct {
+	LastList            *time.Time `json:"lastList,omitempty"`
+	LastContact         *time.Time `json:"lastContact,omitempty"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	LastError           string     `json:"lastError,omitempty"`
+	Staleness           string     `json:"staleness"`
+	Stale               bool       `json:"stale"`
+}
+
+func newDebugHealth(h Health) *debugHealth {
+	dh := &debugHealth{
+		ConsecutiveFailures: h.ConsecutiveFailures,
+		Staleness:           h.Staleness.String(),
+		Stale:               h.Stale,
+	}
+	if !h.LastList.IsZero() {
+		dh.LastList = &h.LastList
+	}
+	if !h.LastContact.IsZero() {
+		dh.LastContact = &h.LastContact
+	}
+	if h.LastError != nil {
+		dh.LastError = h.LastError.Error()
+	}
+	return dh
+}
+
+func newDebugServer(addr string, k *KubeAPI) *debugServer {
+	return &debugServer{addr: addr, k: k}
+}
+
+// start starts listening, unless the server is already listening.
+func (d *debugServer) start() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.ln != nil {
+		return nil
+	}
+	ln, err := reuseport.Listen("tcp", d.addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on debug address %s: %v", d.addr, err)
+	}
+	d.ln = ln
+	mux := http.NewServeMux()
+	mux.HandleFunc("/informers", d.serveInformers)
+	mux.HandleFunc("/objects", d.serveObjects)
+	go http.Serve(ln, mux)
+	return nil
+}
+
+// stop stops listening, if the server is listening.
+func (d *debugServer) stop() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.ln == nil {
+		return nil
+	}
+	err := d.ln.Close()
+	d.ln = nil
+	return err
+}
+
+// informer returns the named Informer, and the apiControl running it, or nil if it does not exist.
+func (d *debugServer) informer(name string) (*Informer, *apiControl) {
+	controls, _ := d.k.APIConn.(clusterControl)
+	cluster, n := splitInformerName(name)
+	dns, ok := controls[cluster]
+	if !ok {
+		return nil, nil
+	}
+	dns.lock.RLock()
+	defer dns.lock.RUnlock()
+	return dns.Informers[n], dns
+}
+
+func (d *debugServer) serveInformers(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(d.k.registrations))
+	for n := range d.k.registrations {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	infs := make([]debugInformer, 0, len(names))
+	for _, n := range names {
+		reg := d.k.registrations[n]
+		cluster, name := splitInformerName(n)
+		inf := debugInformer{
+			Name:        n,
+			Cluster:     cluster,
+			Owner:       reg.owner,
+			Consumers:   reg.consumers,
+			Subscribers: reg.subscribers,
+		}
+		if reg.objType != nil {
+			inf.Type = reg.objType.String()
+		}
+		if i, dns := d.informer(n); i != nil {
+			inf.Synced = i.Controller.HasSynced()
+			inf.Objects = len(i.Lister.ListKeys())
+			if h, ok := dns.Health(name); ok {
+				inf.Health = newDebugHealth(h)
+			}
+		}
+		infs = append(infs, inf)
+	}
+	writeDebugJSON(w, infs)
+}
+
+func (d *debugServer) serveObjects(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	i, _ := d.informer(q.Get("informer"))
+	if i == nil {
+		http.Error(w, fmt.Sprintf("unknown informer %q", q.Get("informer")), http.StatusNotFound)
+		return
+	}
+	namespace, name := q.Get("namespace"), q.Get("name")
+	keys := i.Lister.ListKeys()
+	sort.Strings(keys)
+	objs := []interface{}{}
+	for _, key := range keys {
+		// filter on the store keys, as objects converted by a ConvertFunc may have no object metadata
+		ns, n, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil || (namespace != "" && ns != namespace) || (name != "" && n != name) {
+			continue
+		}
+		obj, exists, err := i.Lister.GetByKey(key)
+		if err != nil || !exists {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	writeDebugJSON(w, objs)
+}
+
+func writeDebugJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Warningf("Failed to write debug response: %v", err)
+	}
+}
@@ -0,0 +1,189 @@
+package k8sapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+	"github.com/coredns/coredns/plugin"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newTestDebugServer(t *testing.T) *debugServer {
+	regs, err := registerInformers([]plugin.Handler{
+		testWatcher{
+			name:      "first",
+			informers: map[string]InformerFunc{"service": testInformerFunc(cache.Indexers{})},
+			regs:      map[string]Registration{"service": {Object: &api.Service{}}},
+		},
+		testWatcher{
+			name:      "second",
+			informers: map[string]InformerFunc{"service": testInformerFunc(cache.Indexers{}), "east/pod": testInformerFunc(cache.Indexers{})},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	for _, svc := range []*api.Service{
+		{ObjectMeta: meta.ObjectMeta{Namespace: "testns", Name: "svc1"}},
+		{ObjectMeta: meta.ObjectMeta{Namespace: "testns", Name: "svc2"}},
+		{ObjectMeta: meta.ObjectMeta{Namespace: "other", Name: "svc1"}},
+	} {
+		store.Add(svc)
+	}
+	k := New([]string{""})
+	k.registrations = regs
+	k.APIConn = clusterControl{
+		"": {
+			Informers: map[string]*Informer{"service": {Controller: syncedController(true), Lister: store}},
+			health:    map[string]*informerHealth{"service": newInformerHealth()},
+		},
+		"east": {cluster: "east", Informers: map[string]*Informer{
+			"pod": {Controller: syncedController(false), Lister: cache.NewStore(cache.MetaNamespaceKeyFunc)},
+		}},
+	}
+	return newDebugServer("127.0.0.1:0", k)
+}
+
+func TestDebugInformers(t *testing.T) {
+	d := newTestDebugServer(t)
+	rec := httptest.NewRecorder()
+	d.serveInformers(rec, httptest.NewRequest(http.MethodGet, "/informers", nil))
+	var got []debugInformer
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 informers, got %+v", got)
+	}
+	pod, svc := got[0], got[1]
+	if pod.Name != "east/pod" || pod.Cluster != "east" || pod.Owner != "second" || pod.Synced || pod.Health != nil {
+		t.Errorf("Unexpected east/pod informer %+v", pod)
+	}
+	if svc.Name != "service" || svc.Owner != "first" || !svc.Synced || svc.Objects != 3 || svc.Type != "*v1.Service" {
+		t.Errorf("Unexpected service informer %+v", svc)
+	}
+	if !reflect.DeepEqual(svc.Consumers, []string{"first", "second"}) {
+		t.Errorf("Expected consumers [first second], got %v", svc.Consumers)
+	}
+	if svc.Health == nil || svc.Health.LastContact != nil {
+		t.Errorf("Expected health of service informer without contact, got %+v", svc.Health)
+	}
+}
+
+func TestDebugObjects(t *testing.T) {
+	d := newTestDebugServer(t)
+	tests := []struct {
+		query        string
+		expectedCode int
+		expected     []string
+	}{
+		{"informer=service", http.StatusOK, []string{"other/svc1", "testns/svc1", "testns/svc2"}},
+		{"informer=service&namespace=testns", http.StatusOK, []string{"testns/svc1", "testns/svc2"}},
+		{"informer=service&name=svc1", http.StatusOK, []string{"other/svc1", "testns/svc1"}},
+		{"informer=service&namespace=testns&name=svc2", http.StatusOK, []string{"testns/svc2"}},
+		{"informer=service&namespace=missing", http.StatusOK, []string{}},
+		{"informer=east/pod", http.StatusOK, []string{}},
+		{"informer=pod", http.StatusNotFound, nil},
+		{"", http.StatusNotFound, nil},
+	}
+
+	for i, tc := range tests {
+		rec := httptest.NewRecorder()
+		d.serveObjects(rec, httptest.NewRequest(http.MethodGet, "/objects?"+tc.query, nil))
+		if rec.Code != tc.expectedCode {
+			t.Errorf("Test %d: Expected status %d, got %d", i, tc.expectedCode, rec.Code)
+			continue
+		}
+		if rec.Code != http.StatusOK {
+			continue
+		}
+		var svcs []api.Service
+		if err := json.Unmarshal(rec.Body.Bytes(), &svcs); err != nil {
+			t.Fatalf("Test %d: Unexpected error: %v", i, err)
+		}
+		got := []string{}
+		for _, svc := range svcs {
+			got = append(got, svc.Namespace+"/"+svc.Name)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Test %d: Expected %v, got %v", i, tc.expected, got)
+		}
+	}
+}
+
+func TestDebugServerStartStop(t *testing.T) {
+	d := newTestDebugServer(t)
+	if err := d.start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// starting again keeps the listener
+	ln := d.ln
+	if err := d.start(); err != nil || d.ln != ln {
+		t.Fatalf("Expected start to keep the listener, got %v", err)
+	}
+	resp, err := http.Get("http://" + ln.Addr().String() + "/informers")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if err := d.stop(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := d.stop(); err != nil {
+		t.Errorf("Expected stop to be idempotent, got %v", err)
+	}
+}
+
+func TestParseDebug(t *testing.T) {
+	tests := []struct {
+		input        string // Corefile data as string
+		shouldErr    bool
+		expectedAddr string
+	}{
+		{`k8s_api`, false, ""},
+		{`k8s_api {
+			debug localhost:9155
+		}`, false, "localhost:9155"},
+		{`k8s_api {
+			debug :9155
+		}`, false, ":9155"},
+		{`k8s_api {
+			debug localhost
+		}`, true, ""},
+		{`k8s_api {
+			debug
+		}`, true, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+		addr := ""
+		if k.debug != nil {
+			addr = k.debug.addr
+		}
+		if addr != tc.expectedAddr {
+			t.Errorf("Test %d: Expected debug address %q, got %q", i, tc.expectedAddr, addr)
+		}
+	}
+}
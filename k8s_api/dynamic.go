@@ -0,0 +1,67 @@
+package k8sapi
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DynamicResource declares an Informer for an arbitrary resource, such as a CRD, listed and watched with the
+// dynamic client.
+type DynamicResource struct {
+	// Resource is the GroupVersionResource to watch, e.g. {Group: "example.com", Version: "v1", Resource: "dnsrecords"}.
+	Resource schema.GroupVersionResource
+
+	// ClusterScoped should be set for resources that are not namespaced. The namespaces configured in k8s_api are
+	// ignored for cluster scoped resources, but the selectors still apply.
+	ClusterScoped bool
+
+	// Convert converts the *unstructured.Unstructured objects before they are stored. If nil, they are stored as they
+	// are.
+	Convert ConvertFunc
+
+	// Indexers are the indexers of the Informer's store.
+	Indexers cache.Indexers
+}
+
+// DynamicInformer returns an InformerFunc for the resource, which plugins can return from Informers() like any
+// other InformerFunc. Objects that fail to convert are not stored.
+func DynamicInformer(r DynamicResource) InformerFunc {
+	return func(ctx context.Context, _ kubernetes.Interface, opts InformerOptions) *Informer {
+		client := opts.DynamicClient.Resource(r.Resource)
+		if r.ClusterScoped {
+			opts.Namespaces = nil
+		}
+		lw := opts.ListerWatcher(func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(o metav1.ListOptions) (runtime.Object, error) {
+					return client.Namespace(ns).List(ctx, o)
+				},
+				WatchFunc: func(o metav1.ListOptions) (watch.Interface, error) {
+					return client.Namespace(ns).Watch(ctx, o)
+				},
+			}
+		})
+		// The store adds the indexers other plugins register to its map, so it must not be the caller's.
+		indexers := cache.Indexers{}
+		for name, f := range r.Indexers {
+			indexers[name] = f
+		}
+		store := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, indexers)
+		cfg := &cache.Config{
+			Queue:            cache.NewDeltaFIFO(cache.MetaNamespaceKeyFunc, store),
+			ListerWatcher:    lw,
+			ObjectType:       &unstructured.Unstructured{},
+			FullResyncPeriod: 0,
+			RetryOnError:     false,
+			Process:          NewProcessor(store, r.Convert, nil, opts.EventHandler(nil)),
+		}
+		return &Informer{Controller: cache.New(cfg), Lister: store}
+	}
+}
@@ -0,0 +1,138 @@
+package k8sapi
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+var dnsRecordResource = schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "dnsrecords"}
+
+type dnsRecord struct {
+	metav1.ObjectMeta
+	IP string
+}
+
+func newDNSRecord(namespace, name, ip string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("example.com/v1")
+	u.SetKind("DNSRecord")
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	if ip != "" {
+		unstructured.SetNestedField(u.Object, ip, "spec", "ip")
+	}
+	return u
+}
+
+func toDNSRecord(obj interface{}) (interface{}, error) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object %T", obj)
+	}
+	ip, ok, err := unstructured.NestedString(u.Object, "spec", "ip")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("dnsrecord %s/%s has no ip", u.GetNamespace(), u.GetName())
+	}
+	return &dnsRecord{ObjectMeta: metav1.ObjectMeta{Namespace: u.GetNamespace(), Name: u.GetName()}, IP: ip}, nil
+}
+
+// fakeDynamic is a dynamic client that only supports listing and watching namespaced resources.
+type fakeDynamic struct {
+	objects  []*unstructured.Unstructured
+	watchers map[string]*watch.FakeWatcher
+}
+
+func (f *fakeDynamic) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return &fakeResource{client: f}
+}
+
+type fakeResource struct {
+	dynamic.NamespaceableResourceInterface
+	client    *fakeDynamic
+	namespace string
+}
+
+func (r *fakeResource) Namespace(ns string) dynamic.ResourceInterface {
+	return &fakeResource{client: r.client, namespace: ns}
+}
+
+func (r *fakeResource) List(context.Context, metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetResourceVersion("1")
+	for _, o := range r.client.objects {
+		if r.namespace == "" || o.GetNamespace() == r.namespace {
+			list.Items = append(list.Items, *o)
+		}
+	}
+	return list, nil
+}
+
+func (r *fakeResource) Watch(context.Context, metav1.ListOptions) (watch.Interface, error) {
+	return r.client.watchers[r.namespace], nil
+}
+
+func TestDynamicInformer(t *testing.T) {
+	client := &fakeDynamic{
+		objects: []*unstructured.Unstructured{
+			newDNSRecord("ns1", "a", "10.0.0.1"),
+			newDNSRecord("ns2", "b", "10.0.0.2"),
+			newDNSRecord("ns1", "invalid", ""),
+		},
+		watchers: map[string]*watch.FakeWatcher{"ns1": watch.NewFake()},
+	}
+	indexers := cache.Indexers{"ip": func(obj interface{}) ([]string, error) { return []string{obj.(*dnsRecord).IP}, nil }}
+	fn := DynamicInformer(DynamicResource{Resource: dnsRecordResource, Convert: toDNSRecord, Indexers: indexers})
+	inf := fn(context.Background(), nil, InformerOptions{DynamicClient: client, Namespaces: []string{"ns1"}})
+	if err := inf.Lister.(cache.Indexer).AddIndexers(cache.Indexers{"other": indexers["ip"]}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(indexers) != 1 {
+		t.Errorf("expected the indexers of the resource to be unchanged, got %v", indexers)
+	}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go inf.Controller.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, inf.Controller.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	keys := inf.Lister.ListKeys()
+	if len(keys) != 1 || keys[0] != "ns1/a" {
+		t.Fatalf("expected store to hold [ns1/a], got %v", keys)
+	}
+	obj, _, _ := inf.Lister.GetByKey("ns1/a")
+	if r, ok := obj.(*dnsRecord); !ok || r.IP != "10.0.0.1" {
+		t.Fatalf("expected converted dnsRecord, got %#v", obj)
+	}
+	if objs, err := inf.Lister.(cache.Indexer).ByIndex("ip", "10.0.0.1"); err != nil || len(objs) != 1 {
+		t.Errorf("expected ns1/a by index, got %v, %v", objs, err)
+	}
+
+	// Watched objects are converted too.
+	client.watchers["ns1"].Add(newDNSRecord("ns1", "c", "10.0.0.3"))
+	for i := 0; ; i++ {
+		if obj, ok, _ := inf.Lister.GetByKey("ns1/c"); ok {
+			if r := obj.(*dnsRecord); r.IP != "10.0.0.3" {
+				t.Errorf("expected ip %q, got %q", "10.0.0.3", r.IP)
+			}
+			break
+		}
+		if i == 100 {
+			t.Fatal("timed out waiting for watched object")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,116 @@
+package k8sapi
+
+import (
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// Health describes the connection of an Informer to the API, so that plugins can tell how fresh its objects are.
+type Health struct {
+	// LastList is when the Informer last listed its objects from the API.
+	LastList time.Time
+
+	// LastContact is when the Informer last heard from the API: a list, the start of a watch, or a watch event.
+	LastContact time.Time
+
+	// ConsecutiveFailures is the number of failed lists and watches since the last contact, and LastError is the
+	// error of the last of them.
+	ConsecutiveFailures int
+	LastError           error
+
+	// Staleness is how long the Informer has been failing to reach the API, since its last contact or since it
+	// started. It is zero while the Informer is connected.
+	Staleness time.Duration
+
+	// Stale is true if Staleness exceeds the max_staleness option of k8s_api.
+	Stale bool
+}
+
+// informerHealth is a listWatchObserver tracking the lists and watches of an Informer.
+type informerHealth struct {
+	lock        sync.Mutex
+	started     time.Time
+	lastList    time.Time
+	lastContact time.Time
+	failures    int
+	lastError   error
+}
+
+func newInformerHealth() *informerHealth {
+	return &informerHealth{started: time.Now()}
+}
+
+func (h *informerHealth) contacted(list bool) {
+	now := time.Now()
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if list {
+		h.lastList = now
+	}
+	h.lastContact = now
+	h.failures = 0
+	h.lastError = nil
+}
+
+func (h *informerHealth) failed(err error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.failures++
+	h.lastError = err
+}
+
+// health returns the Health of the Informer at the time now. It is stale if its staleness exceeds maxStaleness,
+// unless maxStaleness is 0.
+func (h *informerHealth) health(now time.Time, maxStaleness time.Duration) Health {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	hl := Health{
+		LastList:            h.lastList,
+		LastContact:         h.lastContact,
+		ConsecutiveFailures: h.failures,
+		LastError:           h.lastError,
+	}
+	if h.failures > 0 || h.lastContact.IsZero() {
+		since := h.started
+		if h.lastContact.After(since) {
+			since = h.lastContact
+		}
+		hl.Staleness = now.Sub(since)
+	}
+	hl.Stale = maxStaleness > 0 && hl.Staleness > maxStaleness
+	return hl
+}
+
+var _ listWatchObserver = &informerHealth{}
+
+// listed implements listWatchObserver.
+func (h *informerHealth) listed(_ runtime.Object, _ metav1.ListOptions, err error) {
+	if err != nil {
+		h.failed(err)
+		return
+	}
+	h.contacted(true)
+}
+
+// watched implements listWatchObserver.
+func (h *informerHealth) watched(err error) {
+	if err != nil {
+		h.failed(err)
+		return
+	}
+	h.contacted(false)
+}
+
+// observe implements listWatchObserver.
+func (h *informerHealth) observe(e watch.Event) {
+	if e.Type == watch.Error {
+		h.failed(apierrors.FromObject(e.Object))
+		return
+	}
+	h.contacted(false)
+}
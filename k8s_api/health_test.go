@@ -0,0 +1,115 @@
+package k8sapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestHealthListWatch(t *testing.T) {
+	fake := watch.NewFake()
+	listErr := errors.New("list failed")
+	health := newInformerHealth()
+	opts := InformerOptions{health: health, metrics: &informerMetrics{informer: "health-test", control: "1"}}
+	lw := opts.ListerWatcher(func(string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if options.ResourceVersion == "fail" {
+					return nil, listErr
+				}
+				return &api.ServiceList{}, nil
+			},
+			WatchFunc: func(metav1.ListOptions) (watch.Interface, error) { return fake, nil },
+		}
+	})
+
+	started := health.started
+	if h := health.health(started.Add(time.Minute), 30*time.Second); h.Staleness != time.Minute || !h.Stale {
+		t.Errorf("Expected stale health before the first list, got %+v", h)
+	}
+
+	lw.List(metav1.ListOptions{})
+	h := health.health(time.Now(), 30*time.Second)
+	if h.LastList.IsZero() || h.LastContact != h.LastList || h.Staleness != 0 || h.Stale {
+		t.Errorf("Expected fresh health after a list, got %+v", h)
+	}
+
+	lw.List(metav1.ListOptions{ResourceVersion: "fail"})
+	lw.List(metav1.ListOptions{ResourceVersion: "fail"})
+	h = health.health(h.LastContact.Add(time.Minute), 30*time.Second)
+	if h.ConsecutiveFailures != 2 || h.LastError != listErr || h.Staleness != time.Minute || !h.Stale {
+		t.Errorf("Expected stale health after failed lists, got %+v", h)
+	}
+	if h := health.health(h.LastContact.Add(time.Minute), 0); h.Stale {
+		t.Errorf("Expected health not to be stale without max staleness, got %+v", h)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// the watch of the API is wrapped once, for all the observers of the Informer
+	if m, ok := w.(*multiWatch); !ok || len(m.watches) != 1 || m.watches[0] != fake {
+		t.Errorf("Expected the watch to be wrapped once, got %T", w)
+	}
+	if h := health.health(time.Now(), 30*time.Second); h.ConsecutiveFailures != 0 || h.Staleness != 0 {
+		t.Errorf("Expected fresh health after a watch, got %+v", h)
+	}
+	go func() {
+		fake.Add(&api.Service{})
+		fake.Error(&metav1.Status{Status: metav1.StatusFailure, Code: 410, Reason: metav1.StatusReasonExpired})
+		fake.Stop()
+	}()
+	for range w.ResultChan() {
+	}
+	if h := health.health(time.Now(), 30*time.Second); h.ConsecutiveFailures != 1 || h.LastError == nil {
+		t.Errorf("Expected a failure after a watch error, got %+v", h)
+	}
+}
+
+func TestParseMaxStaleness(t *testing.T) {
+	tests := []struct {
+		input                string // Corefile data as string
+		shouldErr            bool
+		expectedMaxStaleness time.Duration
+	}{
+		{`k8s_api`, false, 0},
+		{`k8s_api {
+			max_staleness 5m
+		}`, false, 5 * time.Minute},
+		{`k8s_api {
+			max_staleness 0s
+		}`, true, 0},
+		{`k8s_api {
+			max_staleness
+		}`, true, 0},
+		{`k8s_api {
+			max_staleness 5
+		}`, true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+		if k.maxStaleness != tc.expectedMaxStaleness {
+			t.Errorf("Test %d: Expected max staleness %v, got %v", i, tc.expectedMaxStaleness, k.maxStaleness)
+		}
+	}
+}
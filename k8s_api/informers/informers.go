@@ -0,0 +1,231 @@
+// Package informers provides InformerFuncs for the standard core types, so that plugins do not need to write their
+// own list and watch functions. Plugins return them from k8sapi.APIWatcher.Informers() under their well-known
+// names, so that plugins using the same type share a single Informer:
+//
+//	func (p *MyPlugin) Informers() map[string]k8sapi.InformerFunc {
+//		return map[string]k8sapi.InformerFunc{
+//			informers.Service: informers.ServiceInformer(informers.Options{Convert: toService}),
+//		}
+//	}
+package informers
+
+import (
+	"context"
+	"fmt"
+
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The well-known Informer names of the types provided by this package.
+const (
+	Service       = "service"
+	Endpoints     = "endpoints"
+	EndpointSlice = "endpointslice"
+	Pod           = "pod"
+	Namespace     = "namespace"
+	Node          = "node"
+)
+
+// Options configure an Informer built by this package.
+type Options struct {
+	// LabelSelector and FieldSelector select the objects to watch, in addition to the selectors configured in
+	// k8s_api. For Namespace objects, the namespace label selector of k8s_api applies instead of its label selector.
+	LabelSelector labels.Selector
+	FieldSelector fields.Selector
+
+	// Convert converts objects before they are stored. If nil, the API objects are stored. Objects that fail to
+	// convert are not stored, and a stored object whose update fails to convert is kept.
+	Convert k8sapi.ConvertFunc
+
+	// Indexers are the indexers of the Informer's store, so that it is complete before k8s_api adds the indexers
+	// registered by other plugins.
+	Indexers cache.Indexers
+
+	// Handler receives the add, update and delete events of the Informer, with the stored objects. It may be nil.
+	Handler cache.ResourceEventHandler
+
+	// Observe is called with each added, updated or deleted API object before it is converted, e.g. to record the
+	// latency of changes. It may be nil.
+	Observe func(metav1.Object)
+}
+
+// resource describes how to list and watch a type.
+type resource struct {
+	object runtime.Object
+
+	// clusterScoped is set for types that are not namespaced, and namespace for Namespace objects, which are
+	// selected by the namespaces configured in k8s_api.
+	clusterScoped bool
+	namespace     bool
+
+	list  func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (runtime.Object, error)
+	watch func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (watch.Interface, error)
+}
+
+var resources = map[string]resource{
+	Service: {
+		object: &api.Service{},
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.CoreV1().Services(ns).List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.CoreV1().Services(ns).Watch(ctx, o)
+		},
+	},
+	Endpoints: {
+		object: &api.Endpoints{},
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.CoreV1().Endpoints(ns).List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.CoreV1().Endpoints(ns).Watch(ctx, o)
+		},
+	},
+	EndpointSlice: {
+		object: &discovery.EndpointSlice{},
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.DiscoveryV1beta1().EndpointSlices(ns).List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.DiscoveryV1beta1().EndpointSlices(ns).Watch(ctx, o)
+		},
+	},
+	Pod: {
+		object: &api.Pod{},
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.CoreV1().Pods(ns).List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, ns string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.CoreV1().Pods(ns).Watch(ctx, o)
+		},
+	},
+	Namespace: {
+		object:    &api.Namespace{},
+		namespace: true,
+		list: func(ctx context.Context, c kubernetes.Interface, _ string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.CoreV1().Namespaces().List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, _ string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.CoreV1().Namespaces().Watch(ctx, o)
+		},
+	},
+	Node: {
+		object:        &api.Node{},
+		clusterScoped: true,
+		list: func(ctx context.Context, c kubernetes.Interface, _ string, o metav1.ListOptions) (runtime.Object, error) {
+			return c.CoreV1().Nodes().List(ctx, o)
+		},
+		watch: func(ctx context.Context, c kubernetes.Interface, _ string, o metav1.ListOptions) (watch.Interface, error) {
+			return c.CoreV1().Nodes().Watch(ctx, o)
+		},
+	},
+}
+
+// New returns the InformerFunc of the well-known Informer name, e.g. Service, or an error if the name is unknown.
+func New(name string, o Options) (k8sapi.InformerFunc, error) {
+	r, ok := resources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown informer %q", name)
+	}
+	return r.informer(o), nil
+}
+
+// ServiceInformer returns an InformerFunc for Service objects.
+func ServiceInformer(o Options) k8sapi.InformerFunc {
+	return resources[Service].informer(o)
+}
+
+// EndpointsInformer returns an InformerFunc for Endpoints objects.
+func EndpointsInformer(o Options) k8sapi.InformerFunc {
+	return resources[Endpoints].informer(o)
+}
+
+// EndpointSliceInformer returns an InformerFunc for discovery.k8s.io/v1beta1 EndpointSlice objects.
+func EndpointSliceInformer(o Options) k8sapi.InformerFunc {
+	return resources[EndpointSlice].informer(o)
+}
+
+// PodInformer returns an InformerFunc for Pod objects.
+func PodInformer(o Options) k8sapi.InformerFunc {
+	return resources[Pod].informer(o)
+}
+
+// NamespaceInformer returns an InformerFunc for Namespace objects. The namespaces configured in k8s_api select the
+// Namespace objects to watch.
+func NamespaceInformer(o Options) k8sapi.InformerFunc {
+	return resources[Namespace].informer(o)
+}
+
+// NodeInformer returns an InformerFunc for Node objects. The namespaces configured in k8s_api are ignored for Nodes, but
+// the selectors still apply.
+func NodeInformer(o Options) k8sapi.InformerFunc {
+	return resources[Node].informer(o)
+}
+
+func (r resource) informer(o Options) k8sapi.InformerFunc {
+	return func(ctx context.Context, client kubernetes.Interface, opts k8sapi.InformerOptions) *k8sapi.Informer {
+		lw := func(ns string) cache.ListerWatcher {
+			return &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					return r.list(ctx, client, ns, o.selectors(options))
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					return r.watch(ctx, client, ns, o.selectors(options))
+				},
+			}
+		}
+		var listWatch cache.ListerWatcher
+		switch {
+		case r.namespace:
+			listWatch = opts.NamespaceListerWatcher(lw(api.NamespaceAll))
+		case r.clusterScoped:
+			opts.Namespaces = nil
+			listWatch = opts.ListerWatcher(lw)
+		default:
+			listWatch = opts.ListerWatcher(lw)
+		}
+		// The store adds the indexers other plugins register to its map, so it must not be the caller's.
+		indexers := cache.Indexers{}
+		for name, f := range o.Indexers {
+			indexers[name] = f
+		}
+		store := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, indexers)
+		cfg := &cache.Config{
+			Queue:            cache.NewDeltaFIFO(cache.MetaNamespaceKeyFunc, store),
+			ListerWatcher:    listWatch,
+			ObjectType:       r.object,
+			FullResyncPeriod: 0,
+			RetryOnError:     false,
+			Process:          k8sapi.NewProcessor(store, o.Convert, o.Observe, opts.EventHandler(o.Handler)),
+		}
+		return &k8sapi.Informer{Controller: cache.New(cfg), Lister: store}
+	}
+}
+
+// selectors adds the selectors of the options to the list options.
+func (o Options) selectors(options metav1.ListOptions) metav1.ListOptions {
+	if o.LabelSelector != nil && !o.LabelSelector.Empty() {
+		options.LabelSelector = joinSelectors(options.LabelSelector, o.LabelSelector.String())
+	}
+	if o.FieldSelector != nil && !o.FieldSelector.Empty() {
+		options.FieldSelector = joinSelectors(options.FieldSelector, o.FieldSelector.String())
+	}
+	return options
+}
+
+func joinSelectors(current, s string) string {
+	if current == "" {
+		return s
+	}
+	return current + "," + s
+}
@@ -0,0 +1,157 @@
+package informers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// compactService is a Service converted by toCompact.
+type compactService struct {
+	metav1.ObjectMeta
+	ClusterIP string
+}
+
+func toCompact(obj interface{}) (interface{}, error) {
+	svc, ok := obj.(*api.Service)
+	if !ok {
+		return nil, errors.New("unexpected object")
+	}
+	if svc.Spec.ClusterIP == "" {
+		return nil, errors.New("no cluster IP")
+	}
+	return &compactService{ObjectMeta: svc.ObjectMeta, ClusterIP: svc.Spec.ClusterIP}, nil
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{Service, Endpoints, EndpointSlice, Pod, Namespace, Node} {
+		if f, err := New(name, Options{}); err != nil || f == nil {
+			t.Errorf("Expected informer for %q, got %v", name, err)
+		}
+	}
+	if _, err := New("ingress", Options{}); err == nil {
+		t.Error("Expected error for unknown informer")
+	}
+}
+
+func TestServicesInformer(t *testing.T) {
+	var lock sync.Mutex
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		queries = append(queries, r.URL.Path+"?"+r.URL.Query().Get("labelSelector")+"&"+r.URL.Query().Get("fieldSelector"))
+		lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		json.NewEncoder(w).Encode(&api.ServiceList{
+			TypeMeta: metav1.TypeMeta{Kind: "ServiceList", APIVersion: "v1"},
+			ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			Items: []api.Service{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "testns", Name: "svc1"}, Spec: api.ServiceSpec{ClusterIP: "10.0.0.1"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "testns", Name: "headless"}},
+			},
+		})
+	}))
+	defer srv.Close()
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var adds int
+	f := ServiceInformer(Options{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"app": "dns"}),
+		FieldSelector: fields.OneTermEqualSelector("spec.type", "ClusterIP"),
+		Convert:       toCompact,
+		Indexers: cache.Indexers{"ip": func(obj interface{}) ([]string, error) {
+			return []string{obj.(*compactService).ClusterIP}, nil
+		}},
+		Handler: cache.ResourceEventHandlerFuncs{AddFunc: func(interface{}) { adds++ }},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	inf := f(ctx, client, k8sapi.InformerOptions{Namespaces: []string{"testns"}})
+	go inf.Controller.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), inf.Controller.HasSynced) {
+		t.Fatal("Expected informer to sync")
+	}
+
+	obj, exists, err := inf.Lister.GetByKey("testns/svc1")
+	if err != nil || !exists {
+		t.Fatalf("Expected testns/svc1, got %v", inf.Lister.ListKeys())
+	}
+	if svc, ok := obj.(*compactService); !ok || svc.ClusterIP != "10.0.0.1" {
+		t.Errorf("Expected compact service with cluster IP 10.0.0.1, got %+v", obj)
+	}
+	if objs, err := inf.Lister.(cache.Indexer).ByIndex("ip", "10.0.0.1"); err != nil || len(objs) != 1 {
+		t.Errorf("Expected testns/svc1 by index, got %v, %v", objs, err)
+	}
+	if keys := inf.Lister.ListKeys(); len(keys) != 1 {
+		t.Errorf("Expected only objects that convert to be stored, got %v", keys)
+	}
+	if adds != 1 {
+		t.Errorf("Expected 1 add event, got %d", adds)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if want := "/api/v1/namespaces/testns/services?app=dns&spec.type=ClusterIP"; queries[0] != want {
+		t.Errorf("Expected list %s, got %s", want, queries[0])
+	}
+}
+
+func TestIndexersCopied(t *testing.T) {
+	ipIndex := func(obj interface{}) ([]string, error) { return nil, nil }
+	o := Options{Indexers: cache.Indexers{"ip": ipIndex}}
+	f := ServiceInformer(o)
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: "http://localhost"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// stores of two builds, to which other plugins add indexers
+	for _, name := range []string{"a", "b"} {
+		inf := f(context.Background(), client, k8sapi.InformerOptions{})
+		if err := inf.Lister.(cache.Indexer).AddIndexers(cache.Indexers{name: ipIndex}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if indexers := inf.Lister.(cache.Indexer).GetIndexers(); len(indexers) != 2 {
+			t.Errorf("Expected the ip and %s indexers, got %v", name, indexers)
+		}
+	}
+	if len(o.Indexers) != 1 {
+		t.Errorf("Expected the indexers of the options to be unchanged, got %v", o.Indexers)
+	}
+}
+
+func TestSelectors(t *testing.T) {
+	o := Options{LabelSelector: labels.Everything()}
+	if got := o.selectors(metav1.ListOptions{LabelSelector: "a=b"}); got.LabelSelector != "a=b" || got.FieldSelector != "" {
+		t.Errorf("Expected unchanged selectors, got %+v", got)
+	}
+	o = Options{
+		LabelSelector: labels.SelectorFromSet(labels.Set{"c": "d"}),
+		FieldSelector: fields.OneTermNotEqualSelector("status.phase", "Failed"),
+	}
+	got := o.selectors(metav1.ListOptions{LabelSelector: "a=b"})
+	if got.LabelSelector != "a=b,c=d" || got.FieldSelector != "status.phase!=Failed" {
+		t.Errorf("Expected joined selectors, got %+v", got)
+	}
+}
@@ -1,39 +1,152 @@
-// Package kubernetes provides the kubernetes backend.
+// Package k8sapi implements the k8s_api plugin, which enables plugins implementing APIWatcher to register Kubernetes
+// API Informers and share their stores.
 package k8sapi
 
 import (
-	"context"
 	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
 
+	"github.com/coredns/coredns/plugin"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
-// KubeAPI implements a plugin that connects to a Kubernetes cluster.
+// KubeAPI implements a plugin that connects to one or more Kubernetes clusters.
 type KubeAPI struct {
-	APIServer     string
-	APICertAuth   string
-	APIClientCert string
-	APIClientKey  string
-	ClientConfig  clientcmd.ClientConfig
-	APIConn       apiController
+	Next plugin.Handler
+	Connection
+	APIConn apiController
+
+	// clusters are the named cluster connections, in addition to the default Connection.
+	clusters map[string]*Connection
 
 	namespaces map[string]struct{}
 	selector labels.Selector
 	nsSelector labels.Selector
+	fieldSelector fields.Selector
+
+	// syncTimeout is how long startup waits for the Informers to sync. If syncFail is set,
+	// startup fails if they have not synced by then.
+	syncTimeout time.Duration
+	syncFail    bool
+
+	// maxStaleness is how long an Informer may fail to reach the API before its Health is stale, if not zero.
+	maxStaleness time.Duration
+
+	// snapshotDir is the directory Informer snapshots are written to every snapshotInterval, if not empty.
+	snapshotDir      string
+	snapshotInterval time.Duration
+
+	// debug serves the state of the Informers over HTTP, if enabled. registrations are the Informers registered by
+	// the plugins, by name.
+	debug         *debugServer
+	registrations map[string]*registration
 }
 
 // New returns a initialized Kubernetes. It default interfaceAddrFunc to return 127.0.0.1. All other
 // values default to their zero value, primaryZoneIndex will thus point to the first zone.
 func New(zones []string) *KubeAPI {
 	k := new(KubeAPI)
+	k.namespaces = make(map[string]struct{})
+	k.clusters = make(map[string]*Connection)
+	k.syncTimeout = defaultSyncTimeout
 	return k
 }
 
-func (k *KubeAPI) getClientConfig() (*rest.Config, error) {
+const defaultSyncTimeout = 5 * time.Second
+
+// Connection holds the settings of a connection to a Kubernetes API.
+type Connection struct {
+	APIServer     string
+	APICertAuth   string
+	APIClientCert string
+	APIClientKey  string
+	ClientConfig  clientcmd.ClientConfig
+
+	// kubeconfig and kubecontext are the kubeconfig file and context ClientConfig is loaded from.
+	kubeconfig  string
+	kubecontext string
+
+	// token, tokenFile and exec authenticate a connection to APIServer, instead of a client certificate.
+	token     string
+	tokenFile string
+	exec      *clientcmdapi.ExecConfig
+
+	// manifests is a file or directory of manifests served in place of an API server, loaded again when they change
+	// if followManifests is set.
+	manifests       string
+	followManifests bool
+
+	// Transport settings applied to every connection, whether in-cluster or not.
+	impersonate   rest.ImpersonationConfig
+	proxy         *url.URL
+	tlsServerName string
+	insecure      bool
+
+	// Client settings applied to every connection, whether in-cluster or not. Zero values use the client-go defaults,
+	// except contentType, which defaults to protobuf.
+	qps         float32
+	burst       int
+	userAgent   string
+	timeout     time.Duration
+	contentType string
+}
+
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/vnd.kubernetes.protobuf"
+)
+
+// getClientConfig returns the rest.Config of the connection, with the client settings applied.
+func (k *Connection) getClientConfig() (*rest.Config, error) {
+	cc, err := k.loadClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cc.ContentType = contentTypeProtobuf
+	if k.contentType != "" {
+		cc.ContentType = k.contentType
+	}
+	if k.qps > 0 {
+		cc.QPS = k.qps
+	}
+	if k.burst > 0 {
+		cc.Burst = k.burst
+	}
+	if k.userAgent != "" {
+		cc.UserAgent = k.userAgent
+	}
+	if k.timeout > 0 {
+		cc.Timeout = k.timeout
+	}
+	if k.impersonate.UserName != "" {
+		cc.Impersonate = k.impersonate
+	}
+	if k.proxy != nil {
+		cc.Proxy = http.ProxyURL(k.proxy)
+	}
+	if k.tlsServerName != "" {
+		cc.ServerName = k.tlsServerName
+	}
+	if k.insecure {
+		// client-go refuses to skip the verification of a server certificate when a CA is given
+		cc.Insecure = true
+		cc.CAFile, cc.CAData = "", nil
+	}
+	return cc, nil
+}
+
+func (k *Connection) loadClientConfig() (*rest.Config, error) {
+	if k.kubeconfig != "" {
+		// ClientConfig caches the kubeconfig file once loaded, so load it again to pick up changes
+		return newKubeconfigClientConfig(k.kubeconfig, k.kubecontext).ClientConfig()
+	}
 	if k.ClientConfig != nil {
 		return k.ClientConfig.ClientConfig()
 	}
@@ -44,12 +157,7 @@ func (k *KubeAPI) getClientConfig() (*rest.Config, error) {
 
 	// Connect to API from in cluster if APIServer is not specified
 	if k.APIServer  == "" {
-		cc, err := rest.InClusterConfig()
-		if err != nil {
-			return nil, err
-		}
-		cc.ContentType = "application/vnd.kubernetes.protobuf"
-		return cc, err
+		return rest.InClusterConfig()
 	}
 
 	// Connect to API from out of cluster
@@ -64,36 +172,67 @@ func (k *KubeAPI) getClientConfig() (*rest.Config, error) {
 	if len(k.APIClientKey) > 0 {
 		authinfo.ClientKey = k.APIClientKey
 	}
+	authinfo.Token = k.token
+	authinfo.TokenFile = k.tokenFile
+	authinfo.Exec = k.exec
 
 	overrides.ClusterInfo = clusterinfo
 	overrides.AuthInfo = authinfo
 	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
 
-	cc, err := clientConfig.ClientConfig()
-	if err != nil {
-		return nil, err
+	return clientConfig.ClientConfig()
+}
+
+// validate checks that the connection options can be used together.
+func (k *Connection) validate() error {
+	auth := 0
+	for _, set := range []bool{k.token != "", k.tokenFile != "", k.exec != nil} {
+		if set {
+			auth++
+		}
+	}
+	switch {
+	case auth > 1:
+		return fmt.Errorf("only one of token, token_file and exec can be set")
+	case auth > 0 && k.ClientConfig != nil:
+		return fmt.Errorf("token, token_file and exec cannot be used with kubeconfig")
+	case auth > 0 && k.APIServer == "":
+		return fmt.Errorf("token, token_file and exec require endpoint")
+	case k.manifests != "" && (k.APIServer != "" || k.ClientConfig != nil || auth > 0):
+		return fmt.Errorf("manifests cannot be used with endpoint, kubeconfig, token, token_file or exec")
+	case k.insecure && k.APICertAuth != "":
+		return fmt.Errorf("insecure_skip_verify cannot be used with a tls CA cert")
 	}
-	cc.ContentType = "application/vnd.kubernetes.protobuf"
-	return cc, err
+	return nil
+}
 
+// newKubeconfigClientConfig returns a ClientConfig loading the context of the kubeconfig file.
+func newKubeconfigClientConfig(kubeconfig, context string) clientcmd.ClientConfig {
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: context},
+	)
 }
 
-// InitKubeCache initializes a new Kubernetes cache.
-func (k *KubeAPI) InitKubeCache(ctx context.Context) (err error) {
-	config, err := k.getClientConfig()
-	if err != nil {
-		return err
+// connection returns the connection to the named cluster, or the default connection if name is empty.
+func (k *KubeAPI) connection(name string) (*Connection, bool) {
+	if name == "" {
+		return &k.Connection, true
 	}
+	conn, ok := k.clusters[name]
+	return conn, ok
+}
 
-	kubeClient, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("failed to create kubernetes notification controller: %q", err)
+// informerOptions returns the options passed to every InformerFunc.
+func (k *KubeAPI) informerOptions() InformerOptions {
+	opts := InformerOptions{
+		LabelSelector:          k.selector,
+		NamespaceLabelSelector: k.nsSelector,
+		FieldSelector:          k.fieldSelector,
 	}
-
-	k.APIConn = &apiControl{
-		client:            kubeClient,
-		stopCh:            make(chan struct{}),
+	for ns := range k.namespaces {
+		opts.Namespaces = append(opts.Namespaces, ns)
 	}
-
-	return err
+	sort.Strings(opts.Namespaces)
+	return opts
 }
@@ -0,0 +1,282 @@
+// Package listers provides typed handles on the shared stores of Informers holding the compact objects of the object
+// package, so that plugins do not need to type assert the stores passed to SetIndexer, or the objects in them:
+//
+//	func (p *MyPlugin) SetIndexer(name string, l cache.KeyListerGetter) error {
+//		if name != "service" {
+//			return nil
+//		}
+//		svcs, err := listers.NewServiceLister(l)
+//		if err != nil {
+//			return err
+//		}
+//		p.services = svcs
+//		return nil
+//	}
+//
+// The constructors return an error if the store does not hold objects of the expected type, which makes k8s_api fail
+// to start, rather than the plugin failing to find objects at runtime.
+package listers
+
+import (
+	"fmt"
+	"reflect"
+
+	k8sapi "github.com/chrisohaver/k8s_api/k8s_api"
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	serviceType   = reflect.TypeOf(&object.Service{})
+	podType       = reflect.TypeOf(&object.Pod{})
+	endpointsType = reflect.TypeOf(&object.Endpoints{})
+	sliceType     = reflect.TypeOf(&object.EndpointSlice{})
+)
+
+// indexer returns the store of l, if it is an Indexer holding objects of type t. The declared object type of a
+// TypedLister is checked, and otherwise the objects already in the store.
+func indexer(l cache.KeyListerGetter, t reflect.Type) (cache.Indexer, error) {
+	idx, ok := l.(cache.Indexer)
+	if !ok {
+		return nil, fmt.Errorf("expected Indexer holding %v, got %T", t, l)
+	}
+	if tl, ok := l.(k8sapi.TypedLister); ok {
+		if tl.ObjectType() != t {
+			return nil, fmt.Errorf("expected Indexer holding %v, got Indexer holding %v", t, tl.ObjectType())
+		}
+		return idx, nil
+	}
+	for _, obj := range idx.List() {
+		if reflect.TypeOf(obj) != t {
+			return nil, fmt.Errorf("expected Indexer holding %v, got Indexer holding %T", t, obj)
+		}
+	}
+	return idx, nil
+}
+
+// unexpected returns the error for an object of the wrong type in a store.
+func unexpected(obj interface{}, t reflect.Type) error {
+	return fmt.Errorf("expected %v, got %T", t, obj)
+}
+
+// ServiceLister is a typed handle on a store of *object.Service.
+type ServiceLister struct {
+	idx cache.Indexer
+}
+
+// NewServiceLister returns a ServiceLister for l, or an error if l is not an Indexer of *object.Service.
+func NewServiceLister(l cache.KeyListerGetter) (*ServiceLister, error) {
+	idx, err := indexer(l, serviceType)
+	if err != nil {
+		return nil, err
+	}
+	return &ServiceLister{idx: idx}, nil
+}
+
+// List returns all Services in the store.
+func (l *ServiceLister) List() []*object.Service {
+	var svcs []*object.Service
+	for _, obj := range l.idx.List() {
+		if s, ok := obj.(*object.Service); ok {
+			svcs = append(svcs, s)
+		}
+	}
+	return svcs
+}
+
+// Get returns the Service with the given namespace and name, and whether it exists.
+func (l *ServiceLister) Get(namespace, name string) (*object.Service, bool, error) {
+	obj, exists, err := l.idx.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	s, ok := obj.(*object.Service)
+	if !ok {
+		return nil, false, unexpected(obj, serviceType)
+	}
+	return s, true, nil
+}
+
+// ByIndex returns the Services whose index values for the named index include value.
+func (l *ServiceLister) ByIndex(index, value string) ([]*object.Service, error) {
+	objs, err := l.idx.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	svcs := make([]*object.Service, 0, len(objs))
+	for _, obj := range objs {
+		s, ok := obj.(*object.Service)
+		if !ok {
+			return nil, unexpected(obj, serviceType)
+		}
+		svcs = append(svcs, s)
+	}
+	return svcs, nil
+}
+
+// PodLister is a typed handle on a store of *object.Pod.
+type PodLister struct {
+	idx cache.Indexer
+}
+
+// NewPodLister returns a PodLister for l, or an error if l is not an Indexer of *object.Pod.
+func NewPodLister(l cache.KeyListerGetter) (*PodLister, error) {
+	idx, err := indexer(l, podType)
+	if err != nil {
+		return nil, err
+	}
+	return &PodLister{idx: idx}, nil
+}
+
+// List returns all Pods in the store.
+func (l *PodLister) List() []*object.Pod {
+	var pods []*object.Pod
+	for _, obj := range l.idx.List() {
+		if p, ok := obj.(*object.Pod); ok {
+			pods = append(pods, p)
+		}
+	}
+	return pods
+}
+
+// Get returns the Pod with the given namespace and name, and whether it exists.
+func (l *PodLister) Get(namespace, name string) (*object.Pod, bool, error) {
+	obj, exists, err := l.idx.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	p, ok := obj.(*object.Pod)
+	if !ok {
+		return nil, false, unexpected(obj, podType)
+	}
+	return p, true, nil
+}
+
+// ByIndex returns the Pods whose index values for the named index include value.
+func (l *PodLister) ByIndex(index, value string) ([]*object.Pod, error) {
+	objs, err := l.idx.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*object.Pod, 0, len(objs))
+	for _, obj := range objs {
+		p, ok := obj.(*object.Pod)
+		if !ok {
+			return nil, unexpected(obj, podType)
+		}
+		pods = append(pods, p)
+	}
+	return pods, nil
+}
+
+// EndpointsLister is a typed handle on a store of *object.Endpoints.
+type EndpointsLister struct {
+	idx cache.Indexer
+}
+
+// NewEndpointsLister returns an EndpointsLister for l, or an error if l is not an Indexer of *object.Endpoints.
+func NewEndpointsLister(l cache.KeyListerGetter) (*EndpointsLister, error) {
+	idx, err := indexer(l, endpointsType)
+	if err != nil {
+		return nil, err
+	}
+	return &EndpointsLister{idx: idx}, nil
+}
+
+// List returns all Endpoints in the store.
+func (l *EndpointsLister) List() []*object.Endpoints {
+	var eps []*object.Endpoints
+	for _, obj := range l.idx.List() {
+		if e, ok := obj.(*object.Endpoints); ok {
+			eps = append(eps, e)
+		}
+	}
+	return eps
+}
+
+// Get returns the Endpoints with the given namespace and name, and whether they exist.
+func (l *EndpointsLister) Get(namespace, name string) (*object.Endpoints, bool, error) {
+	obj, exists, err := l.idx.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	e, ok := obj.(*object.Endpoints)
+	if !ok {
+		return nil, false, unexpected(obj, endpointsType)
+	}
+	return e, true, nil
+}
+
+// ByIndex returns the Endpoints whose index values for the named index include value.
+func (l *EndpointsLister) ByIndex(index, value string) ([]*object.Endpoints, error) {
+	objs, err := l.idx.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	eps := make([]*object.Endpoints, 0, len(objs))
+	for _, obj := range objs {
+		e, ok := obj.(*object.Endpoints)
+		if !ok {
+			return nil, unexpected(obj, endpointsType)
+		}
+		eps = append(eps, e)
+	}
+	return eps, nil
+}
+
+// EndpointSliceLister is a typed handle on a store of *object.EndpointSlice.
+type EndpointSliceLister struct {
+	idx cache.Indexer
+}
+
+// NewEndpointSliceLister returns an EndpointSliceLister for l, or an error if l is not an Indexer of
+// *object.EndpointSlice.
+func NewEndpointSliceLister(l cache.KeyListerGetter) (*EndpointSliceLister, error) {
+	idx, err := indexer(l, sliceType)
+	if err != nil {
+		return nil, err
+	}
+	return &EndpointSliceLister{idx: idx}, nil
+}
+
+// List returns all EndpointSlices in the store.
+func (l *EndpointSliceLister) List() []*object.EndpointSlice {
+	var slices []*object.EndpointSlice
+	for _, obj := range l.idx.List() {
+		if e, ok := obj.(*object.EndpointSlice); ok {
+			slices = append(slices, e)
+		}
+	}
+	return slices
+}
+
+// Get returns the EndpointSlice with the given namespace and name, and whether it exists.
+func (l *EndpointSliceLister) Get(namespace, name string) (*object.EndpointSlice, bool, error) {
+	obj, exists, err := l.idx.GetByKey(namespace + "/" + name)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	e, ok := obj.(*object.EndpointSlice)
+	if !ok {
+		return nil, false, unexpected(obj, sliceType)
+	}
+	return e, true, nil
+}
+
+// ByIndex returns the EndpointSlices whose index values for the named index include value.
+func (l *EndpointSliceLister) ByIndex(index, value string) ([]*object.EndpointSlice, error) {
+	objs, err := l.idx.ByIndex(index, value)
+	if err != nil {
+		return nil, err
+	}
+	slices := make([]*object.EndpointSlice, 0, len(objs))
+	for _, obj := range objs {
+		e, ok := obj.(*object.EndpointSlice)
+		if !ok {
+			return nil, unexpected(obj, sliceType)
+		}
+		slices = append(slices, e)
+	}
+	return slices, nil
+}
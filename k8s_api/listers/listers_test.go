@@ -0,0 +1,126 @@
+package listers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chrisohaver/k8s_api/k8s_api/object"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// typedStore is a store declaring the type of its objects, like the stores k8s_api passes to SetIndexer.
+type typedStore struct {
+	cache.Indexer
+	objType reflect.Type
+}
+
+func (s typedStore) ObjectType() reflect.Type { return s.objType }
+
+func newIndexer(objs ...interface{}) cache.Indexer {
+	idx := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{
+		"ip": func(obj interface{}) ([]string, error) {
+			switch o := obj.(type) {
+			case *object.Service:
+				return []string{o.ClusterIP}, nil
+			case *object.Pod:
+				return []string{o.PodIP}, nil
+			}
+			return nil, nil
+		},
+	})
+	for _, obj := range objs {
+		idx.Add(obj)
+	}
+	return idx
+}
+
+func TestNewLister(t *testing.T) {
+	tests := []struct {
+		lister    cache.KeyListerGetter
+		shouldErr bool
+	}{
+		{newIndexer(), false},
+		{newIndexer(&object.Service{Name: "svc1", Namespace: "testns"}), false},
+		{typedStore{newIndexer(), serviceType}, false},
+		// the declared type is checked
+		{typedStore{newIndexer(), podType}, true},
+		// the objects in the store are checked
+		{newIndexer(&object.Pod{Name: "pod1", Namespace: "testns"}), true},
+		// the store must be an Indexer
+		{struct{ cache.KeyListerGetter }{newIndexer()}, true},
+	}
+	for i, tc := range tests {
+		_, err := NewServiceLister(tc.lister)
+		if err != nil && !tc.shouldErr {
+			t.Errorf("Test %d: Expected no error, got %v", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Errorf("Test %d: Expected error, got none", i)
+		}
+	}
+}
+
+func TestServiceLister(t *testing.T) {
+	l, err := NewServiceLister(newIndexer(
+		&object.Service{Name: "svc1", Namespace: "testns", ClusterIP: "10.0.0.1"},
+		&object.Service{Name: "svc2", Namespace: "testns", ClusterIP: "10.0.0.2"},
+	))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if svcs := l.List(); len(svcs) != 2 {
+		t.Errorf("Expected 2 services, got %d", len(svcs))
+	}
+	svc, exists, err := l.Get("testns", "svc1")
+	if err != nil || !exists || svc.ClusterIP != "10.0.0.1" {
+		t.Errorf("Expected testns/svc1, got %+v, %v, %v", svc, exists, err)
+	}
+	if _, exists, err := l.Get("testns", "svc3"); err != nil || exists {
+		t.Errorf("Expected testns/svc3 not to exist, got %v, %v", exists, err)
+	}
+	svcs, err := l.ByIndex("ip", "10.0.0.2")
+	if err != nil || len(svcs) != 1 || svcs[0].Name != "svc2" {
+		t.Errorf("Expected svc2 by index, got %+v, %v", svcs, err)
+	}
+	if _, err := l.ByIndex("missing", "10.0.0.2"); err == nil {
+		t.Error("Expected error for unknown index")
+	}
+}
+
+func TestPodLister(t *testing.T) {
+	l, err := NewPodLister(newIndexer(&object.Pod{Name: "pod1", Namespace: "testns", PodIP: "10.1.0.1"}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pod, exists, err := l.Get("testns", "pod1")
+	if err != nil || !exists || pod.PodIP != "10.1.0.1" {
+		t.Errorf("Expected testns/pod1, got %+v, %v, %v", pod, exists, err)
+	}
+	pods, err := l.ByIndex("ip", "10.1.0.1")
+	if err != nil || len(pods) != 1 {
+		t.Errorf("Expected pod1 by index, got %+v, %v", pods, err)
+	}
+	if _, err := NewEndpointsLister(typedStore{newIndexer(), podType}); err == nil {
+		t.Error("Expected error for endpoints lister of pods")
+	}
+}
+
+func TestEndpointSliceLister(t *testing.T) {
+	l, err := NewEndpointSliceLister(typedStore{newIndexer(
+		&object.EndpointSlice{Name: "svc1-abc", Namespace: "testns", ServiceName: "svc1"},
+		&object.EndpointSlice{Name: "svc1-def", Namespace: "testns", ServiceName: "svc1"},
+	), sliceType})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if slices := l.List(); len(slices) != 2 {
+		t.Errorf("Expected 2 slices, got %d", len(slices))
+	}
+	if s, exists, err := l.Get("testns", "svc1-def"); err != nil || !exists || s.ServiceName != "svc1" {
+		t.Errorf("Expected testns/svc1-def, got %+v, %v, %v", s, exists, err)
+	}
+	if _, err := NewEndpointSliceLister(typedStore{newIndexer(), endpointsType}); err == nil {
+		t.Error("Expected error for slice lister of endpoints")
+	}
+}
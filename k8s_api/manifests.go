@@ -0,0 +1,640 @@
+package k8sapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+// manifestHost is the API server host of connections to manifests. Requests never leave the process.
+const manifestHost = "http://manifests.k8s-api.invalid"
+
+// manifestWatchBuffer is the number of events buffered for each watch, before the watch is closed and the client has to
+// list the objects again.
+const manifestWatchBuffer = 100
+
+// manifestSource serves the objects of YAML or JSON manifests to the clients of a connection in place of an API
+// server, so that Informers run unchanged without one. It is the http.RoundTripper of the clients, and implements
+// the get, list and watch requests of the API for any resource, with label and field selectors.
+type manifestSource struct {
+	path   string
+	follow bool
+
+	lock      sync.Mutex
+	version   uint64
+	resources map[schema.GroupVersionResource]*manifestResource
+	watchers  map[*manifestWatcher]struct{}
+	sums      map[string][32]byte
+}
+
+// manifestResource holds the objects of a resource, keyed by namespace and name.
+type manifestResource struct {
+	kind    string
+	objects map[string]*unstructured.Unstructured
+}
+
+type manifestWatcher struct {
+	resource  schema.GroupVersionResource
+	namespace string
+	labels    labels.Selector
+	fields    fields.Selector
+	events    chan watch.Event
+}
+
+// newManifestSource loads the manifests at path, a file or a directory. If follow is true, the manifests are
+// loaded again when they change, while the source runs.
+func newManifestSource(path string, follow bool) (*manifestSource, error) {
+	s := &manifestSource{
+		path:      path,
+		follow:    follow,
+		resources: make(map[schema.GroupVersionResource]*manifestResource),
+		watchers:  make(map[*manifestWatcher]struct{}),
+	}
+	files, err := manifestFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := loadManifests(files)
+	if err != nil {
+		return nil, err
+	}
+	s.update(objs)
+	s.sums = fileSums(files)
+	return s, nil
+}
+
+// clientConfig returns a rest.Config for clients of the source, with the client settings of conn.
+func (s *manifestSource) clientConfig(conn *Connection) *rest.Config {
+	config := &rest.Config{
+		Host:      manifestHost,
+		QPS:       conn.qps,
+		Burst:     conn.burst,
+		UserAgent: conn.userAgent,
+		Timeout:   conn.timeout,
+		Transport: s,
+	}
+	config.ContentType = contentTypeJSON
+	return config
+}
+
+// run loads the manifests again when they change, until stopCh is closed.
+func (s *manifestSource) run(stopCh <-chan struct{}) {
+	if !s.follow {
+		return
+	}
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.reloadIfChanged()
+		}
+	}
+}
+
+// reloadIfChanged loads the manifests again if any file was changed, added or removed. If they cannot be loaded,
+// the current objects are kept until the files change again.
+func (s *manifestSource) reloadIfChanged() bool {
+	files, err := manifestFiles(s.path)
+	if err != nil {
+		log.Errorf("Failed to reload manifests: %v", err)
+		return false
+	}
+	sums := fileSums(files)
+	s.lock.Lock()
+	changed := !reflect.DeepEqual(sums, s.sums)
+	s.sums = sums
+	s.lock.Unlock()
+	if !changed {
+		return false
+	}
+
+	objs, err := loadManifests(files)
+	if err != nil {
+		log.Errorf("Failed to reload manifests: %v", err)
+		return false
+	}
+	s.update(objs)
+	log.Infof("Reloaded manifests")
+	return true
+}
+
+// update replaces the objects of the source, and notifies the watchers of the objects added, modified and deleted.
+func (s *manifestSource) update(objs []*unstructured.Unstructured) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	resources := make(map[schema.GroupVersionResource]*manifestResource)
+	for gvr, r := range s.resources {
+		// keep the resources with no objects left, so their lists keep the kind of their objects
+		resources[gvr] = &manifestResource{kind: r.kind, objects: make(map[string]*unstructured.Unstructured)}
+	}
+	for _, obj := range objs {
+		gvr, _ := meta.UnsafeGuessKindToResource(obj.GroupVersionKind())
+		r, ok := resources[gvr]
+		if !ok {
+			r = &manifestResource{kind: obj.GetKind(), objects: make(map[string]*unstructured.Unstructured)}
+			resources[gvr] = r
+		}
+		key := manifestKey(obj.GetNamespace(), obj.GetName())
+		old := s.resources[gvr].object(key)
+		if old != nil {
+			obj.SetResourceVersion(old.GetResourceVersion())
+			if reflect.DeepEqual(obj.Object, old.Object) {
+				r.objects[key] = old
+				continue
+			}
+		}
+		s.version++
+		obj.SetResourceVersion(strconv.FormatUint(s.version, 10))
+		r.objects[key] = obj
+		s.notify(gvr, old, obj)
+	}
+	for gvr, r := range s.resources {
+		var removed []string
+		for key := range r.objects {
+			if resources[gvr].object(key) == nil {
+				removed = append(removed, key)
+			}
+		}
+		// delete in order of keys, so that watchers see the same events on every reload
+		sort.Strings(removed)
+		for _, key := range removed {
+			old := r.objects[key]
+			s.version++
+			obj := old.DeepCopy()
+			obj.SetResourceVersion(strconv.FormatUint(s.version, 10))
+			s.notify(gvr, obj, nil)
+		}
+	}
+	s.resources = resources
+}
+
+// notify sends the change of an object from old to obj to the watchers of its resource. The events are adjusted
+// to each watcher's selectors, e.g. an object whose labels stop matching is deleted.
+func (s *manifestSource) notify(gvr schema.GroupVersionResource, old, obj *unstructured.Unstructured) {
+	for w := range s.watchers {
+		if w.resource != gvr {
+			continue
+		}
+		oldMatch, match := w.matches(old), w.matches(obj)
+		var e watch.Event
+		switch {
+		case oldMatch && match:
+			e = watch.Event{Type: watch.Modified, Object: obj}
+		case match:
+			e = watch.Event{Type: watch.Added, Object: obj}
+		case oldMatch && obj != nil:
+			e = watch.Event{Type: watch.Deleted, Object: obj}
+		case oldMatch:
+			e = watch.Event{Type: watch.Deleted, Object: old}
+		default:
+			continue
+		}
+		select {
+		case w.events <- e:
+		default:
+			// the watcher is too slow, end its watch so that it lists the objects again
+			s.stopWatch(w)
+		}
+	}
+}
+
+// stopWatch unregisters the watcher, and closes its events. s.lock must be held.
+func (s *manifestSource) stopWatch(w *manifestWatcher) {
+	if _, ok := s.watchers[w]; ok {
+		delete(s.watchers, w)
+		close(w.events)
+	}
+}
+
+func (r *manifestResource) object(key string) *unstructured.Unstructured {
+	if r == nil {
+		return nil
+	}
+	return r.objects[key]
+}
+
+func (w *manifestWatcher) matches(obj *unstructured.Unstructured) bool {
+	return obj != nil && manifestMatches(obj, w.namespace, w.labels, w.fields)
+}
+
+// manifestMatches returns true if obj is in the namespace, if not empty, and matches the selectors.
+func manifestMatches(obj *unstructured.Unstructured, namespace string, ls labels.Selector, fs fields.Selector) bool {
+	if namespace != "" && obj.GetNamespace() != namespace {
+		return false
+	}
+	if !ls.Matches(labels.Set(obj.GetLabels())) {
+		return false
+	}
+	set, ok := manifestFields(obj, fs)
+	return ok && fs.Matches(set)
+}
+
+// manifestFields returns the values of the fields of the selector in obj. As the field labels of the API, such as
+// status.phase or spec.nodeName, are the paths of the fields in the objects, a field is looked up by its path, and is
+// empty if obj does not have it. It returns false if a field is not a string, number or bool, which the API cannot
+// select on.
+func manifestFields(obj *unstructured.Unstructured, fs fields.Selector) (fields.Set, bool) {
+	set := fields.Set{}
+	for _, r := range fs.Requirements() {
+		v, _, err := unstructured.NestedFieldNoCopy(obj.Object, strings.Split(r.Field, ".")...)
+		if err != nil {
+			return nil, false
+		}
+		switch v.(type) {
+		case nil:
+			set[r.Field] = ""
+		case string, bool, int64, float64:
+			set[r.Field] = fmt.Sprint(v)
+		default:
+			return nil, false
+		}
+	}
+	return set, true
+}
+
+func manifestKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// manifestFiles returns the .yaml, .yml and .json files of the directory path and its subdirectories, skipping hidden
+// files and directories (e.g. the ..data directory of a mounted ConfigMap), or path itself if it is a file.
+func manifestFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == path {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".") {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch filepath.Ext(p) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				files = append(files, p)
+			}
+		}
+		return nil
+	})
+	return files, err
+}
+
+// loadManifests returns the objects of the files. Each file may hold several YAML documents, and lists of objects.
+func loadManifests(files []string) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	seen := make(map[string]string)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+		for {
+			var raw runtime.RawExtension
+			if err := d.Decode(&raw); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			if len(bytes.TrimSpace(raw.Raw)) == 0 {
+				continue
+			}
+			o, _, err := unstructured.UnstructuredJSONScheme.Decode(raw.Raw, nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			var items []*unstructured.Unstructured
+			switch o := o.(type) {
+			case *unstructured.Unstructured:
+				items = append(items, o)
+			case *unstructured.UnstructuredList:
+				for i := range o.Items {
+					items = append(items, &o.Items[i])
+				}
+			}
+			for _, obj := range items {
+				if obj.GetName() == "" {
+					return nil, fmt.Errorf("%s: %s without name", file, obj.GetKind())
+				}
+				id := fmt.Sprintf("%s %s", obj.GroupVersionKind(), manifestKey(obj.GetNamespace(), obj.GetName()))
+				if f, ok := seen[id]; ok {
+					return nil, fmt.Errorf("%s: duplicate %s, also in %s", file, id, f)
+				}
+				seen[id] = file
+				objs = append(objs, obj)
+			}
+		}
+	}
+	return objs, nil
+}
+
+// RoundTrip implements http.RoundTripper, answering requests for the objects of the source.
+func (s *manifestSource) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	if req.Method != http.MethodGet {
+		return statusResponse(req, apierrors.NewMethodNotSupported(schema.GroupResource{}, req.Method)), nil
+	}
+	if gv, ok := parseManifestGroupVersionPath(req.URL.Path); ok {
+		return s.discover(req, gv)
+	}
+	gvr, namespace, name, ok := parseManifestPath(req.URL.Path)
+	if !ok {
+		return statusResponse(req, apierrors.NewNotFound(schema.GroupResource{}, req.URL.Path)), nil
+	}
+	q := req.URL.Query()
+	ls, err := labels.Parse(q.Get("labelSelector"))
+	if err != nil {
+		return statusResponse(req, apierrors.NewBadRequest(err.Error())), nil
+	}
+	fs, err := fields.ParseSelector(q.Get("fieldSelector"))
+	if err != nil {
+		return statusResponse(req, apierrors.NewBadRequest(err.Error())), nil
+	}
+
+	switch {
+	case name != "":
+		return s.get(req, gvr, manifestKey(namespace, name))
+	case q.Get("watch") == "true" || q.Get("watch") == "1":
+		return s.watch(req, &manifestWatcher{resource: gvr, namespace: namespace, labels: ls, fields: fs})
+	default:
+		return s.list(req, gvr, namespace, ls, fs)
+	}
+}
+
+// discover answers the discovery request of a group version with the resources the manifests have objects of. As
+// with an API server that does not serve the group version, it is not found if they have none, so that clients
+// discovering the resources of a cluster, e.g. to choose between Endpoints and EndpointSlices, use the resources of
+// the manifests.
+func (s *manifestSource) discover(req *http.Request, gv schema.GroupVersion) (*http.Response, error) {
+	list := &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: gv.String(),
+	}
+	s.lock.Lock()
+	for gvr, r := range s.resources {
+		if gvr.GroupVersion() != gv {
+			continue
+		}
+		res := metav1.APIResource{Name: gvr.Resource, Kind: r.kind, Verbs: metav1.Verbs{"get", "list", "watch"}}
+		for _, obj := range r.objects {
+			res.Namespaced = obj.GetNamespace() != ""
+			break
+		}
+		list.APIResources = append(list.APIResources, res)
+	}
+	s.lock.Unlock()
+	if len(list.APIResources) == 0 {
+		return statusResponse(req, apierrors.NewNotFound(schema.GroupResource{}, req.URL.Path)), nil
+	}
+	sort.Slice(list.APIResources, func(i, j int) bool { return list.APIResources[i].Name < list.APIResources[j].Name })
+	return objectResponse(req, http.StatusOK, list)
+}
+
+func (s *manifestSource) get(req *http.Request, gvr schema.GroupVersionResource, key string) (*http.Response, error) {
+	s.lock.Lock()
+	obj := s.resources[gvr].object(key)
+	s.lock.Unlock()
+	if obj == nil {
+		return statusResponse(req, apierrors.NewNotFound(gvr.GroupResource(), key)), nil
+	}
+	return objectResponse(req, http.StatusOK, obj)
+}
+
+func (s *manifestSource) list(req *http.Request, gvr schema.GroupVersionResource, namespace string, ls labels.Selector, fs fields.Selector) (*http.Response, error) {
+	s.lock.Lock()
+	r := s.resources[gvr]
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       manifestKind(gvr, r) + "List",
+	}}
+	list.SetResourceVersion(strconv.FormatUint(s.version, 10))
+	if r != nil {
+		keys := make([]string, 0, len(r.objects))
+		for key := range r.objects {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if obj := r.objects[key]; manifestMatches(obj, namespace, ls, fs) {
+				list.Items = append(list.Items, *obj)
+			}
+		}
+	}
+	s.lock.Unlock()
+	return objectResponse(req, http.StatusOK, list)
+}
+
+// watch streams the events of the watcher. A watch from resource version "" or "0" starts with the objects of the
+// source, while a watch from an older resource version than the current one expires at once, as the events in
+// between are not kept.
+func (s *manifestSource) watch(req *http.Request, w *manifestWatcher) (*http.Response, error) {
+	q := req.URL.Query()
+	var timeout <-chan time.Time
+	if t, err := strconv.Atoi(q.Get("timeoutSeconds")); err == nil && t > 0 {
+		timeout = time.After(time.Duration(t) * time.Second)
+	}
+
+	s.lock.Lock()
+	rv := q.Get("resourceVersion")
+	var initial []watch.Event
+	switch v, err := strconv.ParseUint(rv, 10, 64); {
+	case rv == "" || rv == "0":
+		if r := s.resources[w.resource]; r != nil {
+			for _, obj := range r.objects {
+				if w.matches(obj) {
+					initial = append(initial, watch.Event{Type: watch.Added, Object: obj})
+				}
+			}
+		}
+	case err != nil:
+		s.lock.Unlock()
+		return statusResponse(req, apierrors.NewBadRequest(fmt.Sprintf("invalid resource version %q", rv))), nil
+	case v < s.version:
+		// as the API server does, send the error as an event, so that Informers list the objects again at once
+		err := apierrors.NewResourceExpired(fmt.Sprintf("too old resource version: %s (%d)", rv, s.version))
+		initial = append(initial, watch.Event{Type: watch.Error, Object: apiStatus(err)})
+	}
+	w.events = make(chan watch.Event, manifestWatchBuffer)
+	if len(initial) == 1 && initial[0].Type == watch.Error {
+		close(w.events)
+	} else {
+		s.watchers[w] = struct{}{}
+	}
+	s.lock.Unlock()
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer func() {
+			s.lock.Lock()
+			s.stopWatch(w)
+			s.lock.Unlock()
+			pw.Close()
+		}()
+		enc := json.NewEncoder(pw)
+		for _, e := range initial {
+			if err := encodeWatchEvent(enc, e); err != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case e, ok := <-w.events:
+				if !ok {
+					return
+				}
+				if err := encodeWatchEvent(enc, e); err != nil {
+					return
+				}
+			case <-req.Context().Done():
+				return
+			case <-timeout:
+				return
+			}
+		}
+	}()
+	return newManifestResponse(req, http.StatusOK, pr), nil
+}
+
+func encodeWatchEvent(enc *json.Encoder, e watch.Event) error {
+	data, err := json.Marshal(e.Object)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(&metav1.WatchEvent{Type: string(e.Type), Object: runtime.RawExtension{Raw: data}})
+}
+
+// parseManifestGroupVersionPath returns the group version of the API path of a discovery request, which is either
+// /api/VERSION or /apis/GROUP/VERSION.
+func parseManifestGroupVersionPath(path string) (gv schema.GroupVersion, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) == 2 && parts[0] == "api":
+		return schema.GroupVersion{Version: parts[1]}, true
+	case len(parts) == 3 && parts[0] == "apis":
+		return schema.GroupVersion{Group: parts[1], Version: parts[2]}, true
+	}
+	return gv, false
+}
+
+// parseManifestPath returns the resource, namespace and name of the API path, which is either
+// /api/VERSION/... or /apis/GROUP/VERSION/..., followed by [namespaces/NAMESPACE/]RESOURCE[/NAME].
+func parseManifestPath(path string) (gvr schema.GroupVersionResource, namespace, name string, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 3 && parts[0] == "api":
+		gvr.Version, parts = parts[1], parts[2:]
+	case len(parts) >= 4 && parts[0] == "apis":
+		gvr.Group, gvr.Version, parts = parts[1], parts[2], parts[3:]
+	default:
+		return gvr, "", "", false
+	}
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		namespace, parts = parts[1], parts[2:]
+	}
+	switch len(parts) {
+	case 1:
+		gvr.Resource = parts[0]
+	case 2:
+		gvr.Resource, name = parts[0], parts[1]
+	default:
+		// subresources are not supported
+		return gvr, "", "", false
+	}
+	return gvr, namespace, name, true
+}
+
+// manifestKind returns the kind of the objects of the resource. The kind of resources without objects is looked
+// up in the types known to client-go, so that lists decode into their typed object.
+func manifestKind(gvr schema.GroupVersionResource, r *manifestResource) string {
+	if r != nil {
+		return r.kind
+	}
+	for gvk := range scheme.Scheme.AllKnownTypes() {
+		if gvk.GroupVersion() != gvr.GroupVersion() || strings.HasSuffix(gvk.Kind, "List") {
+			continue
+		}
+		if plural, _ := meta.UnsafeGuessKindToResource(gvk); plural == gvr {
+			return gvk.Kind
+		}
+	}
+	return ""
+}
+
+func objectResponse(req *http.Request, code int, obj interface{}) (*http.Response, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	return newManifestResponse(req, code, ioutil.NopCloser(bytes.NewReader(data))), nil
+}
+
+func statusResponse(req *http.Request, err *apierrors.StatusError) *http.Response {
+	status := apiStatus(err)
+	data, _ := json.Marshal(status)
+	return newManifestResponse(req, int(status.Code), ioutil.NopCloser(bytes.NewReader(data)))
+}
+
+// apiStatus returns the Status object of err, as sent by the API server.
+func apiStatus(err *apierrors.StatusError) *metav1.Status {
+	status := err.Status()
+	status.Kind, status.APIVersion = "Status", "v1"
+	return &status
+}
+
+func newManifestResponse(req *http.Request, code int, body io.ReadCloser) *http.Response {
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{contentTypeJSON}},
+		Body:       body,
+		Request:    req,
+	}
+}
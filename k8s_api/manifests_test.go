@@ -0,0 +1,413 @@
+package k8sapi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const testServices = `apiVersion: v1
+kind: Service
+metadata:
+  name: svc1
+  namespace: testns
+  labels:
+    app: dns
+spec:
+  clusterIP: 10.0.0.1
+  ports:
+  - port: 53
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc2
+  namespace: other
+spec:
+  clusterIP: 10.0.0.2
+`
+
+const testRecords = `{
+  "apiVersion": "v1",
+  "kind": "List",
+  "items": [
+    {"apiVersion": "example.com/v1", "kind": "DNSRecord", "metadata": {"name": "rec1", "namespace": "testns"}},
+    {"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "testns"}}
+  ]
+}`
+
+func writeManifests(t *testing.T, dir string, files map[string]string) {
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newTestManifestClient(t *testing.T, path string) (*manifestSource, kubernetes.Interface) {
+	source, err := newManifestSource(path, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client, err := kubernetes.NewForConfig(source.clientConfig(&Connection{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return source, client
+}
+
+func TestManifestSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{
+		"services.yaml": testServices,
+		"records.json":  testRecords,
+		"README":        "ignored",
+	})
+	source, client := newTestManifestClient(t, dir)
+	ctx := context.Background()
+
+	svcs, err := client.CoreV1().Services("").List(ctx, meta.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(svcs.Items) != 2 || len(svcs.Items[1].Spec.Ports) != 1 || svcs.Items[1].Spec.Ports[0].Port != 53 {
+		t.Errorf("Expected 2 services, got %+v", svcs.Items)
+	}
+	svcs, err = client.CoreV1().Services("").List(ctx, meta.ListOptions{LabelSelector: "app=dns"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(svcs.Items) != 1 || svcs.Items[0].Name != "svc1" {
+		t.Errorf("Expected svc1 matching label selector, got %+v", svcs.Items)
+	}
+	svcs, err = client.CoreV1().Services("other").List(ctx, meta.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(svcs.Items) != 1 || svcs.Items[0].Name != "svc2" {
+		t.Errorf("Expected svc2 in namespace other, got %+v", svcs.Items)
+	}
+	if _, err := client.CoreV1().Namespaces().Get(ctx, "testns", meta.GetOptions{}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if _, err := client.CoreV1().Pods("testns").Get(ctx, "pod", meta.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+	pods, err := client.CoreV1().Pods("").List(ctx, meta.ListOptions{})
+	if err != nil || len(pods.Items) != 0 {
+		t.Errorf("Expected no pods, got %v, %v", pods, err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(source.clientConfig(&Connection{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := dynamicClient.Resource(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "dnsrecords"}).
+		Namespace("testns").List(ctx, meta.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records.Items) != 1 || records.Items[0].GetName() != "rec1" {
+		t.Errorf("Expected rec1, got %+v", records.Items)
+	}
+
+	w, err := client.CoreV1().Services("").Watch(ctx, meta.ListOptions{ResourceVersion: svcs.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer w.Stop()
+	if source.reloadIfChanged() {
+		t.Error("Expected no reload of unchanged manifests")
+	}
+	writeManifests(t, dir, map[string]string{"services.yaml": strings.Replace(testServices, "10.0.0.2", "10.0.0.3", 1)})
+	if !source.reloadIfChanged() {
+		t.Fatal("Expected reload of changed manifests")
+	}
+	os.Remove(filepath.Join(dir, "services.yaml"))
+	if !source.reloadIfChanged() {
+		t.Fatal("Expected reload of removed manifests")
+	}
+	for _, want := range []struct {
+		t    watch.EventType
+		name string
+		ip   string
+	}{{watch.Modified, "svc2", "10.0.0.3"}, {watch.Deleted, "svc2", "10.0.0.3"}, {watch.Deleted, "svc1", "10.0.0.1"}} {
+		select {
+		case e := <-w.ResultChan():
+			svc, ok := e.Object.(*api.Service)
+			if !ok || e.Type != want.t {
+				t.Fatalf("Expected %s event, got %s %+v", want.t, e.Type, e.Object)
+			}
+			if svc.Name != want.name || svc.Spec.ClusterIP != want.ip {
+				t.Errorf("Expected %s with cluster IP %s, got %s with %s", want.name, want.ip, svc.Name, svc.Spec.ClusterIP)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %s event", want.t)
+		}
+	}
+
+	// a watch from an older resource version must list again
+	w, err = client.CoreV1().Services("").Watch(ctx, meta.ListOptions{ResourceVersion: svcs.ResourceVersion})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer w.Stop()
+	select {
+	case e := <-w.ResultChan():
+		if err := apierrors.FromObject(e.Object); e.Type != watch.Error || !apierrors.IsResourceExpired(err) {
+			t.Errorf("Expected expired error, got %s %v", e.Type, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for expired error")
+	}
+}
+
+const testPods = `apiVersion: v1
+kind: Pod
+metadata:
+  name: running
+  namespace: testns
+spec:
+  nodeName: node1
+status:
+  phase: Running
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: failed
+  namespace: testns
+spec:
+  nodeName: node2
+status:
+  phase: Failed
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pending
+  namespace: testns
+`
+
+func TestManifestSourceFieldSelector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"pods.yaml": testPods})
+	_, client := newTestManifestClient(t, dir)
+
+	tests := []struct {
+		selector string
+		expected []string
+	}{
+		{"status.phase!=Succeeded,status.phase!=Failed,status.phase!=Unknown", []string{"pending", "running"}},
+		{"spec.nodeName=node2", []string{"failed"}},
+		{"spec.nodeName=", []string{"pending"}},
+		{"metadata.name=running", []string{"running"}},
+		// fields that are not values cannot be selected on
+		{"metadata!=x", nil},
+	}
+	for i, tc := range tests {
+		pods, err := client.CoreV1().Pods("testns").List(context.Background(), meta.ListOptions{FieldSelector: tc.selector})
+		if err != nil {
+			t.Fatalf("Test %d: Unexpected error: %v", i, err)
+		}
+		var names []string
+		for _, p := range pods.Items {
+			names = append(names, p.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tc.expected, ",") {
+			t.Errorf("Test %d: Expected pods %v, got %v", i, tc.expected, names)
+		}
+	}
+}
+
+func TestManifestSourceInformer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices})
+	source, client := newTestManifestClient(t, dir)
+
+	lw := cache.NewListWatchFromClient(client.CoreV1().RESTClient(), "services", "", fields.Everything())
+	store, controller := cache.NewInformer(lw, &api.Service{}, 0, cache.ResourceEventHandlerFuncs{})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go controller.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, controller.HasSynced) {
+		t.Fatal("Expected informer to sync")
+	}
+	if len(store.ListKeys()) != 2 {
+		t.Errorf("Expected 2 services, got %v", store.ListKeys())
+	}
+
+	writeManifests(t, dir, map[string]string{"services.yaml": "apiVersion: v1\nkind: Service\nmetadata:\n  name: svc3\n  namespace: testns\n"})
+	if !source.reloadIfChanged() {
+		t.Fatal("Expected reload of changed manifests")
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(store.ListKeys()) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected informer to have svc3 only, got %v", store.ListKeys())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, ok, _ := store.GetByKey("testns/svc3"); !ok {
+		t.Errorf("Expected testns/svc3, got %v", store.ListKeys())
+	}
+}
+
+const testEndpointSlices = `apiVersion: discovery.k8s.io/v1beta1
+kind: EndpointSlice
+metadata:
+  name: svc1-abcde
+  namespace: testns
+  labels:
+    kubernetes.io/service-name: svc1
+addressType: IPv4
+endpoints:
+- addresses:
+  - 172.0.0.1
+`
+
+func TestManifestSourceDiscovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices, "slices.yaml": testEndpointSlices})
+	_, client := newTestManifestClient(t, dir)
+
+	resources, err := client.Discovery().ServerResourcesForGroupVersion("discovery.k8s.io/v1beta1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resources.APIResources) != 1 || resources.APIResources[0].Name != "endpointslices" ||
+		resources.APIResources[0].Kind != "EndpointSlice" || !resources.APIResources[0].Namespaced {
+		t.Errorf("Expected namespaced endpointslices, got %+v", resources.APIResources)
+	}
+	resources, err = client.Discovery().ServerResourcesForGroupVersion("v1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(resources.APIResources) != 1 || resources.APIResources[0].Name != "services" {
+		t.Errorf("Expected services, got %+v", resources.APIResources)
+	}
+	if _, err := client.Discovery().ServerResourcesForGroupVersion("discovery.k8s.io/v1"); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected group version without objects not to be found, got %v", err)
+	}
+
+	// the slices are served to an informer, as the example kubernetes plugin builds it in slice mode
+	lw := cache.NewListWatchFromClient(client.DiscoveryV1beta1().RESTClient(), "endpointslices", "", fields.Everything())
+	store, controller := cache.NewInformer(lw, &discovery.EndpointSlice{}, 0, cache.ResourceEventHandlerFuncs{})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go controller.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, controller.HasSynced) {
+		t.Fatal("Expected informer to sync")
+	}
+	if _, ok, _ := store.GetByKey("testns/svc1-abcde"); !ok {
+		t.Errorf("Expected testns/svc1-abcde, got %v", store.ListKeys())
+	}
+}
+
+func TestLoadManifestsErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := []string{
+		"apiVersion: v1\nkind: Service\nmetadata:\n  namespace: testns\n",
+		"apiVersion: v1\nmetadata:\n  name: svc\n",
+		"[invalid",
+		testServices + "---\n" + testServices,
+	}
+	for i, data := range tests {
+		writeManifests(t, dir, map[string]string{"test.yaml": data})
+		if _, err := newManifestSource(filepath.Join(dir, "test.yaml"), false); err == nil {
+			t.Errorf("Test %d: Expected error, got none", i)
+		}
+	}
+}
+
+func TestParseManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input          string // Corefile data as string
+		shouldErr      bool
+		expectedFollow bool
+	}{
+		{`k8s_api {
+			manifests ` + dir + `
+		}`, false, false},
+		{`k8s_api {
+			cluster lab {
+				manifests ` + dir + ` follow
+			}
+		}`, false, true},
+		{`k8s_api {
+			manifests ` + dir + ` watch
+		}`, true, false},
+		{`k8s_api {
+			manifests ` + filepath.Join(dir, "missing") + `
+		}`, true, false},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			manifests ` + dir + `
+		}`, true, false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+		conn := &k.Connection
+		if c, ok := k.clusters["lab"]; ok {
+			conn = c
+		}
+		if conn.manifests != dir || conn.followManifests != tc.expectedFollow {
+			t.Errorf("Test %d: Expected manifests %q (follow %v), got %q (follow %v)", i, dir, tc.expectedFollow,
+				conn.manifests, conn.followManifests)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package k8sapi
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+var (
+	// InformerEventCount is the number of add, update and delete events per informer and event type, from lists as
+	// well as watches.
+	InformerEventCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_events_total",
+		Help:      "Counter of add, update and delete events per informer and event type.",
+	}, []string{"informer", "control", "type"})
+
+	// InformerListCount is the number of list requests made per informer.
+	InformerListCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_lists_total",
+		Help:      "Counter of list requests made per informer.",
+	}, []string{"informer", "control"})
+
+	// InformerListErrorCount is the number of failed list requests per informer.
+	InformerListErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_list_errors_total",
+		Help:      "Counter of failed list requests per informer.",
+	}, []string{"informer", "control"})
+
+	// InformerWatchCount is the number of watches started per informer. Watches are restarted
+	// periodically, and after they fail.
+	InformerWatchCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_watches_total",
+		Help:      "Counter of watches started per informer.",
+	}, []string{"informer", "control"})
+
+	// InformerWatchErrorCount is the number of failed watch requests and watch error events per informer.
+	InformerWatchErrorCount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_watch_errors_total",
+		Help:      "Counter of failed watch requests and watch error events per informer.",
+	}, []string{"informer", "control"})
+
+	// InformerLastEvent is the time of the last add, update or delete event per informer. The time since the last
+	// event can be computed with e.g. `time() - coredns_k8s_api_informer_last_event_timestamp_seconds`.
+	InformerLastEvent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: pluginName,
+		Name:      "informer_last_event_timestamp_seconds",
+		Help:      "The timestamp of the last add, update or delete event per informer.",
+	}, []string{"informer", "control"})
+
+	informerObjectsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(plugin.Namespace, pluginName, "informer_objects"),
+		"The number of objects in the store per informer.",
+		[]string{"informer", "control"}, nil,
+	)
+
+	informerSyncedDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(plugin.Namespace, pluginName, "informer_synced"),
+		"Whether the informer has synced (1) or not (0).",
+		[]string{"informer", "control"}, nil,
+	)
+
+	informerStalenessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(plugin.Namespace, pluginName, "informer_staleness_seconds"),
+		"How long the informer has been failing to reach the API, 0 while it is connected.",
+		[]string{"informer", "control"}, nil,
+	)
+
+	// informers collects the store size and sync status of the informers of the running apiControls.
+	informers = &informerCollector{controls: make(map[*apiControl]string)}
+)
+
+func init() { prometheus.MustRegister(informers) }
+
+// informerCollector is a prometheus.Collector reporting the state of the informers of running apiControls. Several
+// apiControls may run Informers with the same name, e.g. those of two server blocks, or those of the old and new
+// instance during a reload, so each is told apart by a control label, numbering the apiControls in the order their
+// Informers are first built. The other metrics of the Informers have the same label.
+type informerCollector struct {
+	sync.Mutex
+	controls map[*apiControl]string
+	built    uint64
+}
+
+// controlID returns the control label of the apiControl, numbering it if it has none.
+func (c *informerCollector) controlID(dns *apiControl) string {
+	c.Lock()
+	defer c.Unlock()
+	return c.id(dns)
+}
+
+// id returns the control label of the apiControl, numbering it if it has none. c must be locked.
+func (c *informerCollector) id(dns *apiControl) string {
+	if dns.id == "" {
+		c.built++
+		dns.id = strconv.FormatUint(c.built, 10)
+	}
+	return dns.id
+}
+
+func (c *informerCollector) add(dns *apiControl) {
+	c.Lock()
+	defer c.Unlock()
+	c.controls[dns] = c.id(dns)
+}
+
+func (c *informerCollector) remove(dns *apiControl) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.controls, dns)
+}
+
+// Describe implements prometheus.Collector.
+func (c *informerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- informerObjectsDesc
+	ch <- informerSyncedDesc
+	ch <- informerStalenessDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *informerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for dns, id := range c.controls {
+		dns.lock.RLock()
+		for name, w := range dns.Informers {
+			n := InformerName(dns.cluster, name)
+			ch <- prometheus.MustNewConstMetric(informerObjectsDesc, prometheus.GaugeValue, float64(len(w.Lister.ListKeys())), n, id)
+			synced := 0.0
+			if w.Controller.HasSynced() {
+				synced = 1
+			}
+			ch <- prometheus.MustNewConstMetric(informerSyncedDesc, prometheus.GaugeValue, synced, n, id)
+			if h, ok := dns.health[name]; ok {
+				staleness := h.health(now, 0).Staleness
+				ch <- prometheus.MustNewConstMetric(informerStalenessDesc, prometheus.GaugeValue, staleness.Seconds(), n, id)
+			}
+		}
+		dns.lock.RUnlock()
+	}
+}
+
+// informerMetrics records the metrics of an Informer: the lists and watches of its ListerWatchers as a
+// listWatchObserver, and the events passed to its event handler.
+type informerMetrics struct {
+	informer string
+	control  string
+}
+
+var _ listWatchObserver = &informerMetrics{}
+
+// listed implements listWatchObserver.
+func (m *informerMetrics) listed(_ runtime.Object, _ metav1.ListOptions, err error) {
+	InformerListCount.WithLabelValues(m.informer, m.control).Inc()
+	if err != nil {
+		InformerListErrorCount.WithLabelValues(m.informer, m.control).Inc()
+	}
+}
+
+// watched implements listWatchObserver.
+func (m *informerMetrics) watched(err error) {
+	InformerWatchCount.WithLabelValues(m.informer, m.control).Inc()
+	if err != nil {
+		InformerWatchErrorCount.WithLabelValues(m.informer, m.control).Inc()
+	}
+}
+
+// observe implements listWatchObserver. The other events are counted by the event handler.
+func (m *informerMetrics) observe(e watch.Event) {
+	if e.Type == watch.Error {
+		InformerWatchErrorCount.WithLabelValues(m.informer, m.control).Inc()
+	}
+}
+
+// event records an event of the type passed to the event handler of the Informer.
+func (m *informerMetrics) event(typ string) {
+	InformerEventCount.WithLabelValues(m.informer, m.control, typ).Inc()
+	InformerLastEvent.WithLabelValues(m.informer, m.control).Set(float64(time.Now().Unix()))
+}
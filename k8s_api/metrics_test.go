@@ -0,0 +1,142 @@
+package k8sapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestMetricsListWatch(t *testing.T) {
+	fake := watch.NewFake()
+	listErr := errors.New("list failed")
+	metrics := &informerMetrics{informer: "metrics-test", control: "1"}
+	opts := InformerOptions{metrics: metrics, events: &eventRouter{metrics: metrics}}
+	lw := opts.ListerWatcher(func(string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if options.ResourceVersion == "fail" {
+					return nil, listErr
+				}
+				return &api.ServiceList{}, nil
+			},
+			WatchFunc: func(metav1.ListOptions) (watch.Interface, error) { return fake, nil },
+		}
+	})
+	handler := opts.EventHandler(nil)
+
+	// the counters are global, so the test checks how much they grow
+	count := func(c *prometheus.CounterVec, labels ...string) float64 {
+		return testutil.ToFloat64(c.WithLabelValues(append([]string{"metrics-test", "1"}, labels...)...))
+	}
+	lists, listErrs := count(InformerListCount), count(InformerListErrorCount)
+	watches, watchErrs := count(InformerWatchCount), count(InformerWatchErrorCount)
+	events := make(map[string]float64)
+	for _, typ := range []string{"add", "update", "delete"} {
+		events[typ] = count(InformerEventCount, typ)
+	}
+
+	lw.List(metav1.ListOptions{})
+	lw.List(metav1.ListOptions{ResourceVersion: "fail"})
+	if n := count(InformerListCount) - lists; n != 2 {
+		t.Errorf("expected 2 lists, got %v", n)
+	}
+	if n := count(InformerListErrorCount) - listErrs; n != 1 {
+		t.Errorf("expected 1 list error, got %v", n)
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	go func() {
+		fake.Add(&api.Service{})
+		fake.Error(&metav1.Status{})
+		fake.Stop()
+	}()
+	for range w.ResultChan() {
+	}
+	if n := count(InformerWatchCount) - watches; n != 1 {
+		t.Errorf("expected 1 watch, got %v", n)
+	}
+	if n := count(InformerWatchErrorCount) - watchErrs; n != 1 {
+		t.Errorf("expected 1 watch error, got %v", n)
+	}
+
+	// the events of lists and watches are counted by the event handler
+	handler.OnAdd(&api.Service{})
+	handler.OnUpdate(&api.Service{}, &api.Service{})
+	handler.OnDelete(&api.Service{})
+	for typ, before := range events {
+		if n := count(InformerEventCount, typ) - before; n != 1 {
+			t.Errorf("expected 1 %s event, got %v", typ, n)
+		}
+	}
+	if n := testutil.ToFloat64(InformerLastEvent.WithLabelValues("metrics-test", "1")); n == 0 {
+		t.Error("expected last event timestamp to be set")
+	}
+}
+
+func TestInformerEventMetrics(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, dir, map[string]string{"services.yaml": testServices})
+
+	plugins := []plugin.Handler{testWatcher{name: "first", informers: map[string]InformerFunc{"service": serviceInformer}}}
+	regs, err := registerInformers(plugins)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	k := New(nil)
+	k.manifests = dir
+	dns, err := k.buildAPIControl("", &k.Connection, regs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	go dns.Run()
+	defer dns.Stop()
+	waitFor(t, "informer to sync", dns.HasSynced)
+
+	// the objects of the first list are counted as added, with the control label of the collector
+	if dns.id == "" {
+		t.Fatal("Expected the apiControl to be numbered")
+	}
+	if n := testutil.ToFloat64(InformerEventCount.WithLabelValues("service", dns.id, "add")); n != 2 {
+		t.Errorf("Expected 2 add events, got %v", n)
+	}
+}
+
+func TestInformerCollector(t *testing.T) {
+	c := &informerCollector{controls: make(map[*apiControl]string)}
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+
+	// two apiControls with the same Informer, as during a reload
+	for i := 0; i < 2; i++ {
+		c.add(&apiControl{Informers: map[string]*Informer{"service": {
+			Controller: syncedController(true),
+			Lister:     cache.NewStore(cache.MetaNamespaceKeyFunc),
+		}}})
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, mf := range mfs {
+		if n := len(mf.GetMetric()); n != 2 {
+			t.Errorf("Expected 2 %s metrics, got %d", mf.GetName(), n)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package k8sapi
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// multiWatch merges the events of several watches into a single result channel.
+// If any of the watches ends, all of them are stopped.
+type multiWatch struct {
+	watches []watch.Interface
+	result  chan watch.Event
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newMultiWatch returns a multiWatch for watches. If observe is not nil, it is called with the index of the
+// watch and the event, for each event before it is passed on.
+func newMultiWatch(watches []watch.Interface, observe func(int, watch.Event)) *multiWatch {
+	m := &multiWatch{
+		watches: watches,
+		result:  make(chan watch.Event),
+		stopCh:  make(chan struct{}),
+	}
+	m.wg.Add(len(watches))
+	for i := range watches {
+		go m.receive(i, observe)
+	}
+	go func() {
+		m.wg.Wait()
+		close(m.result)
+	}()
+	return m
+}
+
+// Stop implements watch.Interface.
+func (m *multiWatch) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+		for _, w := range m.watches {
+			w.Stop()
+		}
+	})
+}
+
+// ResultChan implements watch.Interface.
+func (m *multiWatch) ResultChan() <-chan watch.Event { return m.result }
+
+func (m *multiWatch) receive(i int, observe func(int, watch.Event)) {
+	defer m.wg.Done()
+	defer m.Stop()
+	w := m.watches[i]
+	for {
+		select {
+		case <-m.stopCh:
+			return
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			if observe != nil {
+				observe(i, e)
+			}
+			select {
+			case m.result <- e:
+			case <-m.stopCh:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package k8sapi
+
+import (
+	"sync"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ListWatchFunc returns a ListerWatcher for the given namespace. The namespace is api.NamespaceAll when
+// k8s_api is not restricted to a set of namespaces.
+type ListWatchFunc func(namespace string) cache.ListerWatcher
+
+// ListerWatcher returns a ListerWatcher scoped to the namespaces and selectors configured in k8s_api. If no
+// namespaces are configured, lw is called once with api.NamespaceAll. Otherwise, lw is called once per namespace,
+// and the resulting lists and watches are merged so that a single Informer can be used for all of them.
+func (o InformerOptions) ListerWatcher(lw ListWatchFunc) cache.ListerWatcher {
+	if len(o.Namespaces) == 0 {
+		return newSelectorListWatch(o.track(lw(api.NamespaceAll), api.NamespaceAll), o.LabelSelector, o.FieldSelector)
+	}
+	return newNamespacedListWatch(o.Namespaces, func(ns string) cache.ListerWatcher {
+		return newSelectorListWatch(o.track(lw(ns), ns), o.LabelSelector, o.FieldSelector)
+	})
+}
+
+// NamespaceListerWatcher returns a ListerWatcher for Namespace objects, scoped to the namespaces and namespace
+// label selector configured in k8s_api. lw should list and watch all namespaces.
+func (o InformerOptions) NamespaceListerWatcher(lw cache.ListerWatcher) cache.ListerWatcher {
+	if len(o.Namespaces) == 0 {
+		return newSelectorListWatch(o.track(lw, ""), o.NamespaceLabelSelector, nil)
+	}
+	return newNamespacedListWatch(o.Namespaces, func(name string) cache.ListerWatcher {
+		return newSelectorListWatch(o.track(lw, name), o.NamespaceLabelSelector,
+			fields.OneTermEqualSelector("metadata.name", name))
+	})
+}
+
+// track returns lw, recording the health and metrics of its lists and watches, and keeping snapshots of its objects
+// in the namespace if snapshots are enabled.
+func (o InformerOptions) track(lw cache.ListerWatcher, namespace string) cache.ListerWatcher {
+	t := &trackedListWatch{lw: lw}
+	if o.health != nil {
+		t.observers = append(t.observers, o.health)
+	}
+	if o.metrics != nil {
+		t.observers = append(t.observers, o.metrics)
+	}
+	if o.snapshots != nil && o.snapshotKey != "" {
+		t.snapshot = o.snapshots.snapshot(o.informer, namespace, o.snapshotKey)
+		t.observers = append(t.observers, t.snapshot)
+	}
+	if len(t.observers) == 0 {
+		return lw
+	}
+	return t
+}
+
+// newNamespacedListWatch returns a ListerWatcher that merges the ListerWatchers returned by lw for each namespace.
+func newNamespacedListWatch(namespaces []string, lw ListWatchFunc) cache.ListerWatcher {
+	if len(namespaces) == 1 {
+		return lw(namespaces[0])
+	}
+	nlw := &namespacedListWatch{
+		namespaces: namespaces,
+		lws:        make(map[string]cache.ListerWatcher, len(namespaces)),
+		versions:   make(map[string]string, len(namespaces)),
+	}
+	for _, ns := range namespaces {
+		nlw.lws[ns] = lw(ns)
+	}
+	return nlw
+}
+
+// namespacedListWatch lists and watches several namespaces, presenting them as a single ListerWatcher.
+// Resource versions are tracked per namespace, so the resource version passed in by the Reflector is ignored.
+type namespacedListWatch struct {
+	namespaces []string
+	lws        map[string]cache.ListerWatcher
+
+	lock     sync.Mutex
+	versions map[string]string
+}
+
+var _ cache.ListerWatcher = &namespacedListWatch{}
+
+// List lists all namespaces and merges the items into the first list returned.
+func (n *namespacedListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	// Paging across namespaces is not supported, and the Reflector's resource version only applies to
+	// a single namespace, so only the "any version" hint is passed on.
+	options.Limit = 0
+	options.Continue = ""
+	if options.ResourceVersion != "0" {
+		options.ResourceVersion = ""
+	}
+	options.ResourceVersionMatch = ""
+
+	var (
+		merged runtime.Object
+		items  []runtime.Object
+	)
+	versions := make(map[string]string, len(n.namespaces))
+	for _, ns := range n.namespaces {
+		list, err := n.lws[ns].List(options)
+		if err != nil {
+			return nil, err
+		}
+		lm, err := meta.ListAccessor(list)
+		if err != nil {
+			return nil, err
+		}
+		versions[ns] = lm.GetResourceVersion()
+		objs, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, objs...)
+		if merged == nil {
+			merged = list
+		}
+	}
+	if err := meta.SetList(merged, items); err != nil {
+		return nil, err
+	}
+
+	n.lock.Lock()
+	n.versions = versions
+	n.lock.Unlock()
+	return merged, nil
+}
+
+// Watch starts a watch in each namespace from the last resource version seen in that namespace.
+func (n *namespacedListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	watches := make([]watch.Interface, 0, len(n.namespaces))
+	for _, ns := range n.namespaces {
+		opts := options
+		n.lock.Lock()
+		opts.ResourceVersion = n.versions[ns]
+		n.lock.Unlock()
+		w, err := n.lws[ns].Watch(opts)
+		if err != nil {
+			for _, w := range watches {
+				w.Stop()
+			}
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+	return newMultiWatch(watches, func(i int, e watch.Event) {
+		if e.Type == watch.Error {
+			return
+		}
+		if o, err := meta.Accessor(e.Object); err == nil {
+			n.setVersion(n.namespaces[i], o.GetResourceVersion())
+		}
+	}), nil
+}
+
+func (n *namespacedListWatch) setVersion(ns, rv string) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.versions[ns] = rv
+}
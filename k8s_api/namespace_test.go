@@ -0,0 +1,136 @@
+package k8sapi
+
+import (
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+type fakeNamespace struct {
+	version string
+	watcher *watch.FakeWatcher
+	watchRV string
+}
+
+func TestNamespacedListWatch(t *testing.T) {
+	fakes := map[string]*fakeNamespace{
+		"ns1": {version: "10", watcher: watch.NewFake()},
+		"ns2": {version: "20", watcher: watch.NewFake()},
+	}
+	opts := InformerOptions{Namespaces: []string{"ns1", "ns2"}}
+	lw := opts.ListerWatcher(func(ns string) cache.ListerWatcher {
+		f := fakes[ns]
+		return &cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				if options.Limit != 0 || options.Continue != "" {
+					t.Errorf("expected paging to be disabled for %s", ns)
+				}
+				return &api.ServiceList{
+					ListMeta: metav1.ListMeta{ResourceVersion: f.version},
+					Items:    []api.Service{{ObjectMeta: metav1.ObjectMeta{Name: "svc", Namespace: ns}}},
+				}, nil
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				f.watchRV = options.ResourceVersion
+				return f.watcher, nil
+			},
+		}
+	})
+
+	list, err := lw.List(metav1.ListOptions{ResourceVersion: "0", Limit: 500})
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+
+	w, err := lw.Watch(metav1.ListOptions{ResourceVersion: "20"})
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	for ns, f := range fakes {
+		if f.watchRV != f.version {
+			t.Errorf("expected watch of %s to start at %q, got %q", ns, f.version, f.watchRV)
+		}
+	}
+
+	go fakes["ns2"].watcher.Add(&api.Service{ObjectMeta: metav1.ObjectMeta{Name: "svc2", Namespace: "ns2", ResourceVersion: "21"}})
+	e := <-w.ResultChan()
+	if e.Type != watch.Added {
+		t.Fatalf("expected %v event, got %v", watch.Added, e.Type)
+	}
+	if svc := e.Object.(*api.Service); svc.Name != "svc2" {
+		t.Fatalf("expected svc2, got %s", svc.Name)
+	}
+
+	// Ending one watch ends the merged watch, and a new watch resumes each namespace at its last version.
+	fakes["ns1"].watcher.Stop()
+	for range w.ResultChan() {
+	}
+	fakes["ns1"].watcher = watch.NewFake()
+	fakes["ns2"].watcher = watch.NewFake()
+	if _, err := lw.Watch(metav1.ListOptions{}); err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	if rv := fakes["ns1"].watchRV; rv != "10" {
+		t.Errorf("expected watch of ns1 to start at %q, got %q", "10", rv)
+	}
+	if rv := fakes["ns2"].watchRV; rv != "21" {
+		t.Errorf("expected watch of ns2 to start at %q, got %q", "21", rv)
+	}
+}
+
+func TestListerWatcherAllNamespaces(t *testing.T) {
+	var got []string
+	InformerOptions{}.ListerWatcher(func(ns string) cache.ListerWatcher {
+		got = append(got, ns)
+		return &cache.ListWatch{}
+	})
+	if len(got) != 1 || got[0] != api.NamespaceAll {
+		t.Errorf("expected a single call for all namespaces, got %v", got)
+	}
+}
+
+func TestListerWatcherSelectors(t *testing.T) {
+	opts := InformerOptions{
+		Namespaces:             []string{"ns1"},
+		LabelSelector:          labels.SelectorFromSet(labels.Set{"app": "dns"}),
+		NamespaceLabelSelector: labels.SelectorFromSet(labels.Set{"team": "a"}),
+		FieldSelector:          fields.OneTermNotEqualSelector("metadata.name", "skip"),
+	}
+	var got metav1.ListOptions
+	lw := &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			got = options
+			return &api.ServiceList{}, nil
+		},
+	}
+
+	opts.ListerWatcher(func(string) cache.ListerWatcher { return lw }).List(metav1.ListOptions{FieldSelector: "spec.nodeName=n1"})
+	if got.LabelSelector != "app=dns" {
+		t.Errorf("expected label selector %q, got %q", "app=dns", got.LabelSelector)
+	}
+	if got.FieldSelector != "spec.nodeName=n1,metadata.name!=skip" {
+		t.Errorf("expected field selector %q, got %q", "spec.nodeName=n1,metadata.name!=skip", got.FieldSelector)
+	}
+
+	opts.NamespaceListerWatcher(lw).List(metav1.ListOptions{})
+	if got.LabelSelector != "team=a" {
+		t.Errorf("expected label selector %q, got %q", "team=a", got.LabelSelector)
+	}
+	if got.FieldSelector != "metadata.name=ns1" {
+		t.Errorf("expected field selector %q, got %q", "metadata.name=ns1", got.FieldSelector)
+	}
+}
@@ -14,17 +14,32 @@ type Endpoints struct {
 	Name      string
 	Namespace string
 	Index     string
-	IndexIP   []string
-	Subsets   []EndpointSubset
+	// IndexIP are the IPs of the ready and not ready addresses.
+	IndexIP []string
+	Subsets []EndpointSubset
 
 	*Empty
 }
 
 // EndpointSubset is a group of addresses with a common set of ports. The
 // expanded set of endpoints is the Cartesian product of Addresses x Ports.
+// NotReadyAddresses are the addresses of endpoints that are not ready, which
+// are only served for services that publish them.
 type EndpointSubset struct {
-	Addresses []EndpointAddress
-	Ports     []EndpointPort
+	Addresses         []EndpointAddress
+	NotReadyAddresses []EndpointAddress
+	Ports             []EndpointPort
+}
+
+// ServedAddresses returns the addresses of the subset to serve: the ready
+// addresses, followed by the not ready addresses if publishNotReady is set.
+func (s EndpointSubset) ServedAddresses(publishNotReady bool) []EndpointAddress {
+	if !publishNotReady || len(s.NotReadyAddresses) == 0 {
+		return s.Addresses
+	}
+	addrs := make([]EndpointAddress, 0, len(s.Addresses)+len(s.NotReadyAddresses))
+	addrs = append(addrs, s.Addresses...)
+	return append(addrs, s.NotReadyAddresses...)
 }
 
 // EndpointAddress is a tuple that describes single IP address.
@@ -33,6 +48,12 @@ type EndpointAddress struct {
 	Hostname      string
 	NodeName      string
 	TargetRefName string
+
+	// Serving is set if the endpoint can serve, which ready endpoints do, and
+	// endpoints may still do while Terminating. Endpoints objects only have
+	// readiness, so only EndpointSlices set Terminating.
+	Serving     bool
+	Terminating bool
 }
 
 // EndpointPort is a tuple that describes a single port.
@@ -69,6 +90,9 @@ func toEndpoints(skipCleanup bool, end *api.Endpoints) *Endpoints {
 		sub := EndpointSubset{
 			Addresses: make([]EndpointAddress, len(eps.Addresses)),
 		}
+		if len(eps.NotReadyAddresses) > 0 {
+			sub.NotReadyAddresses = make([]EndpointAddress, len(eps.NotReadyAddresses))
+		}
 		if len(eps.Ports) == 0 {
 			// Add sentinel if there are no ports.
 			sub.Ports = []EndpointPort{{Port: -1}}
@@ -77,14 +101,11 @@ func toEndpoints(skipCleanup bool, end *api.Endpoints) *Endpoints {
 		}
 
 		for j, a := range eps.Addresses {
-			ea := EndpointAddress{IP: a.IP, Hostname: a.Hostname}
-			if a.NodeName != nil {
-				ea.NodeName = *a.NodeName
-			}
-			if a.TargetRef != nil {
-				ea.TargetRefName = a.TargetRef.Name
-			}
-			sub.Addresses[j] = ea
+			sub.Addresses[j] = toEndpointAddress(a)
+			sub.Addresses[j].Serving = true
+		}
+		for j, a := range eps.NotReadyAddresses {
+			sub.NotReadyAddresses[j] = toEndpointAddress(a)
 		}
 
 		for k, p := range eps.Ports {
@@ -99,6 +120,9 @@ func toEndpoints(skipCleanup bool, end *api.Endpoints) *Endpoints {
 		for _, a := range eps.Addresses {
 			e.IndexIP = append(e.IndexIP, a.IP)
 		}
+		for _, a := range eps.NotReadyAddresses {
+			e.IndexIP = append(e.IndexIP, a.IP)
+		}
 	}
 
 	if !skipCleanup {
@@ -108,6 +132,17 @@ func toEndpoints(skipCleanup bool, end *api.Endpoints) *Endpoints {
 	return e
 }
 
+func toEndpointAddress(a api.EndpointAddress) EndpointAddress {
+	ea := EndpointAddress{IP: a.IP, Hostname: a.Hostname}
+	if a.NodeName != nil {
+		ea.NodeName = *a.NodeName
+	}
+	if a.TargetRef != nil {
+		ea.TargetRefName = a.TargetRef.Name
+	}
+	return ea
+}
+
 // CopyWithoutSubsets copies e, without the subsets.
 func (e *Endpoints) CopyWithoutSubsets() *Endpoints {
 	e1 := &Endpoints{
@@ -140,9 +175,10 @@ func (e *Endpoints) DeepCopyObject() runtime.Object {
 			Addresses: make([]EndpointAddress, len(eps.Addresses)),
 			Ports:     make([]EndpointPort, len(eps.Ports)),
 		}
-		for j, a := range eps.Addresses {
-			ea := EndpointAddress{IP: a.IP, Hostname: a.Hostname, NodeName: a.NodeName, TargetRefName: a.TargetRefName}
-			sub.Addresses[j] = ea
+		copy(sub.Addresses, eps.Addresses)
+		if len(eps.NotReadyAddresses) > 0 {
+			sub.NotReadyAddresses = make([]EndpointAddress, len(eps.NotReadyAddresses))
+			copy(sub.NotReadyAddresses, eps.NotReadyAddresses)
 		}
 		for k, p := range eps.Ports {
 			ep := EndpointPort{Port: p.Port, Name: p.Name, Protocol: p.Protocol}
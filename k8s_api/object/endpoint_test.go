@@ -0,0 +1,79 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestToEndpoints(t *testing.T) {
+	eps := &api.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "svc1", Namespace: "testns", ResourceVersion: "1"},
+		Subsets: []api.EndpointSubset{
+			{
+				Addresses: []api.EndpointAddress{
+					{IP: "10.0.0.1", Hostname: "ep1", NodeName: stringPtr("node1"), TargetRef: &api.ObjectReference{Name: "pod1"}},
+				},
+				NotReadyAddresses: []api.EndpointAddress{{IP: "10.0.0.2", Hostname: "ep2"}},
+				Ports:             []api.EndpointPort{{Name: "http", Port: 80, Protocol: api.ProtocolTCP}},
+			},
+			// a subset without ports gets the sentinel port
+			{NotReadyAddresses: []api.EndpointAddress{{IP: "10.0.0.3"}}},
+		},
+	}
+
+	obj, err := ToEndpoints(false)(eps)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	e := obj.(*Endpoints)
+	if e.Name != "svc1" || e.Namespace != "testns" || e.Version != "1" || e.Index != "svc1.testns" {
+		t.Errorf("Unexpected endpoints metadata: %+v", e)
+	}
+	expected := []EndpointSubset{
+		{
+			Addresses:         []EndpointAddress{{IP: "10.0.0.1", Hostname: "ep1", NodeName: "node1", TargetRefName: "pod1", Serving: true}},
+			NotReadyAddresses: []EndpointAddress{{IP: "10.0.0.2", Hostname: "ep2"}},
+			Ports:             []EndpointPort{{Port: 80, Name: "http", Protocol: "TCP"}},
+		},
+		{
+			Addresses:         []EndpointAddress{},
+			NotReadyAddresses: []EndpointAddress{{IP: "10.0.0.3"}},
+			Ports:             []EndpointPort{{Port: -1}},
+		},
+	}
+	if !reflect.DeepEqual(e.Subsets, expected) {
+		t.Errorf("Expected subsets %+v, got %+v", expected, e.Subsets)
+	}
+	if !reflect.DeepEqual(e.IndexIP, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}) {
+		t.Errorf("Expected ready and not ready addresses to be indexed, got %v", e.IndexIP)
+	}
+	if e1 := e.DeepCopyObject(); !reflect.DeepEqual(e1, e) {
+		t.Errorf("Expected copy %+v, got %+v", e, e1)
+	}
+	if eps.Name != "" {
+		t.Error("Expected converted endpoints to be cleaned up")
+	}
+
+	if _, err := ToEndpoints(true)(&api.Service{}); err == nil {
+		t.Error("Expected error for unexpected object")
+	}
+}
+
+func TestServedAddresses(t *testing.T) {
+	sub := EndpointSubset{
+		Addresses:         []EndpointAddress{{IP: "10.0.0.1"}},
+		NotReadyAddresses: []EndpointAddress{{IP: "10.0.0.2"}},
+	}
+	if addrs := sub.ServedAddresses(false); !reflect.DeepEqual(addrs, []EndpointAddress{{IP: "10.0.0.1"}}) {
+		t.Errorf("Expected only ready addresses, got %+v", addrs)
+	}
+	if addrs := sub.ServedAddresses(true); !reflect.DeepEqual(addrs, []EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}) {
+		t.Errorf("Expected ready and not ready addresses, got %+v", addrs)
+	}
+	if len(sub.Addresses) != 1 {
+		t.Error("Expected subset addresses not to be modified")
+	}
+}
@@ -0,0 +1,173 @@
+package object
+
+import (
+	"fmt"
+	"sort"
+
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// EndpointSlice is a stripped down discovery.EndpointSlice with only the items we need for CoreDNS.
+type EndpointSlice struct {
+	// Don't add new fields to this struct without talking to the CoreDNS maintainers.
+	Version   string
+	Name      string
+	Namespace string
+	// ServiceName is the name of the service the slice belongs to, and Index its EndpointsKey.
+	ServiceName string
+	Index       string
+	IndexIP     []string
+	// Addresses are the addresses of ready endpoints, and NotReadyAddresses those of endpoints that are not ready.
+	Addresses         []EndpointAddress
+	NotReadyAddresses []EndpointAddress
+	Ports             []EndpointPort
+
+	*Empty
+}
+
+// ToEndpointSlice returns a function that converts a *discovery.EndpointSlice to a *EndpointSlice.
+func ToEndpointSlice(skipCleanup bool) ToFunc {
+	return func(obj interface{}) (interface{}, error) {
+		ends, ok := obj.(*discovery.EndpointSlice)
+		if !ok {
+			return nil, fmt.Errorf("unexpected object %v", obj)
+		}
+		return toEndpointSlice(skipCleanup, ends), nil
+	}
+}
+
+// toEndpointSlice converts a *discovery.EndpointSlice to a *EndpointSlice. Endpoints with an unknown ready condition
+// are ready, and those with an unknown serving condition serve if ready. Slices of FQDN addresses have no addresses,
+// as CoreDNS serves IPs.
+func toEndpointSlice(skipCleanup bool, ends *discovery.EndpointSlice) *EndpointSlice {
+	e := &EndpointSlice{
+		Version:     ends.GetResourceVersion(),
+		Name:        ends.GetName(),
+		Namespace:   ends.GetNamespace(),
+		ServiceName: ends.Labels[discovery.LabelServiceName],
+	}
+	if e.ServiceName != "" {
+		e.Index = EndpointsKey(e.ServiceName, e.Namespace)
+	}
+
+	if len(ends.Ports) == 0 {
+		// Add sentinel if there are no ports.
+		e.Ports = []EndpointPort{{Port: -1}}
+	} else {
+		e.Ports = make([]EndpointPort, len(ends.Ports))
+	}
+	for k, p := range ends.Ports {
+		ep := EndpointPort{Port: -1, Protocol: string(api.ProtocolTCP)}
+		if p.Port != nil {
+			ep.Port = *p.Port
+		}
+		if p.Name != nil {
+			ep.Name = *p.Name
+		}
+		if p.Protocol != nil {
+			ep.Protocol = string(*p.Protocol)
+		}
+		e.Ports[k] = ep
+	}
+
+	if ends.AddressType != discovery.AddressTypeFQDN {
+		for _, end := range ends.Endpoints {
+			ready := end.Conditions.Ready == nil || *end.Conditions.Ready
+			serving := ready
+			if end.Conditions.Serving != nil {
+				serving = *end.Conditions.Serving
+			}
+			terminating := end.Conditions.Terminating != nil && *end.Conditions.Terminating
+			for _, a := range end.Addresses {
+				ea := EndpointAddress{IP: a, NodeName: end.Topology["kubernetes.io/hostname"], Serving: serving, Terminating: terminating}
+				if end.Hostname != nil {
+					ea.Hostname = *end.Hostname
+				}
+				if end.TargetRef != nil {
+					ea.TargetRefName = end.TargetRef.Name
+				}
+				e.IndexIP = append(e.IndexIP, a)
+				if !ready {
+					e.NotReadyAddresses = append(e.NotReadyAddresses, ea)
+					continue
+				}
+				e.Addresses = append(e.Addresses, ea)
+			}
+		}
+	}
+
+	if !skipCleanup {
+		*ends = discovery.EndpointSlice{}
+	}
+
+	return e
+}
+
+// EndpointsFromSlices aggregates EndpointSlices into the Endpoints of the services they belong to, with a subset
+// per slice, ordered by slice name. Slices that do not belong to a service are ignored.
+func EndpointsFromSlices(slices []*EndpointSlice) []*Endpoints {
+	sorted := make([]*EndpointSlice, len(slices))
+	copy(sorted, slices)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var eps []*Endpoints
+	services := make(map[string]*Endpoints)
+	for _, s := range sorted {
+		if s.Index == "" {
+			continue
+		}
+		e, ok := services[s.Index]
+		if !ok {
+			e = &Endpoints{Name: s.ServiceName, Namespace: s.Namespace, Index: s.Index}
+			services[s.Index] = e
+			eps = append(eps, e)
+		}
+		e.Subsets = append(e.Subsets, EndpointSubset{Addresses: s.Addresses, NotReadyAddresses: s.NotReadyAddresses, Ports: s.Ports})
+		e.IndexIP = append(e.IndexIP, s.IndexIP...)
+	}
+	return eps
+}
+
+var _ runtime.Object = &EndpointSlice{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (e *EndpointSlice) DeepCopyObject() runtime.Object {
+	e1 := &EndpointSlice{
+		Version:     e.Version,
+		Name:        e.Name,
+		Namespace:   e.Namespace,
+		ServiceName: e.ServiceName,
+		Index:       e.Index,
+		IndexIP:     make([]string, len(e.IndexIP)),
+		Addresses:   make([]EndpointAddress, len(e.Addresses)),
+		Ports:       make([]EndpointPort, len(e.Ports)),
+	}
+	copy(e1.IndexIP, e.IndexIP)
+	copy(e1.Addresses, e.Addresses)
+	copy(e1.Ports, e.Ports)
+	if len(e.NotReadyAddresses) > 0 {
+		e1.NotReadyAddresses = make([]EndpointAddress, len(e.NotReadyAddresses))
+		copy(e1.NotReadyAddresses, e.NotReadyAddresses)
+	}
+	return e1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (e *EndpointSlice) GetNamespace() string { return e.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (e *EndpointSlice) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (e *EndpointSlice) GetName() string { return e.Name }
+
+// SetName implements the metav1.Object interface.
+func (e *EndpointSlice) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (e *EndpointSlice) GetResourceVersion() string { return e.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (e *EndpointSlice) SetResourceVersion(version string) {}
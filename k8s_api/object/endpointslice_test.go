@@ -0,0 +1,136 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func boolPtr(b bool) *bool       { return &b }
+func stringPtr(s string) *string { return &s }
+func int32Ptr(i int32) *int32    { return &i }
+
+func TestToEndpointSlice(t *testing.T) {
+	udp := api.ProtocolUDP
+	slice := &discovery.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "svc1-abc",
+			Namespace:       "testns",
+			ResourceVersion: "1",
+			Labels:          map[string]string{discovery.LabelServiceName: "svc1"},
+		},
+		AddressType: discovery.AddressTypeIPv4,
+		Endpoints: []discovery.Endpoint{
+			{
+				Addresses:  []string{"10.0.0.1"},
+				Conditions: discovery.EndpointConditions{Ready: boolPtr(true)},
+				Hostname:   stringPtr("ep1"),
+				TargetRef:  &api.ObjectReference{Name: "pod1"},
+				Topology:   map[string]string{"kubernetes.io/hostname": "node1"},
+			},
+			// an unknown ready condition is ready
+			{Addresses: []string{"10.0.0.2"}},
+			{Addresses: []string{"10.0.0.3"}, Conditions: discovery.EndpointConditions{Ready: boolPtr(false)}},
+			{
+				Addresses:  []string{"10.0.0.4"},
+				Conditions: discovery.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(true), Terminating: boolPtr(true)},
+			},
+		},
+		Ports: []discovery.EndpointPort{
+			{Name: stringPtr("dns"), Port: int32Ptr(53), Protocol: &udp},
+			// the port of a slice selecting all ports is unset
+			{},
+		},
+	}
+
+	obj, err := ToEndpointSlice(false)(slice)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	e := obj.(*EndpointSlice)
+	if e.Name != "svc1-abc" || e.Namespace != "testns" || e.Version != "1" || e.ServiceName != "svc1" || e.Index != "svc1.testns" {
+		t.Errorf("Unexpected slice metadata: %+v", e)
+	}
+	expected := []EndpointAddress{
+		{IP: "10.0.0.1", Hostname: "ep1", NodeName: "node1", TargetRefName: "pod1", Serving: true},
+		{IP: "10.0.0.2", Serving: true},
+	}
+	if !reflect.DeepEqual(e.Addresses, expected) {
+		t.Errorf("Expected ready addresses %+v, got %+v", expected, e.Addresses)
+	}
+	expected = []EndpointAddress{{IP: "10.0.0.3"}, {IP: "10.0.0.4", Serving: true, Terminating: true}}
+	if !reflect.DeepEqual(e.NotReadyAddresses, expected) {
+		t.Errorf("Expected not ready addresses %+v, got %+v", expected, e.NotReadyAddresses)
+	}
+	if !reflect.DeepEqual(e.IndexIP, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}) {
+		t.Errorf("Expected ready and not ready addresses to be indexed, got %v", e.IndexIP)
+	}
+	expectedPorts := []EndpointPort{{Port: 53, Name: "dns", Protocol: "UDP"}, {Port: -1, Protocol: "TCP"}}
+	if !reflect.DeepEqual(e.Ports, expectedPorts) {
+		t.Errorf("Expected ports %+v, got %+v", expectedPorts, e.Ports)
+	}
+	if slice.Name != "" {
+		t.Error("Expected converted slice to be cleaned up")
+	}
+
+	// slices of FQDNs have no addresses, and slices without ports get the sentinel port
+	fqdn := &discovery.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Name: "ext", Namespace: "testns"},
+		AddressType: discovery.AddressTypeFQDN,
+		Endpoints:   []discovery.Endpoint{{Addresses: []string{"example.com"}}},
+	}
+	obj, err = ToEndpointSlice(true)(fqdn)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	e = obj.(*EndpointSlice)
+	if len(e.Addresses) != 0 || e.Index != "" || !reflect.DeepEqual(e.Ports, []EndpointPort{{Port: -1}}) {
+		t.Errorf("Unexpected FQDN slice: %+v", e)
+	}
+	if fqdn.Name != "ext" {
+		t.Error("Expected slice not to be cleaned up")
+	}
+
+	if _, err := ToEndpointSlice(true)(&api.Endpoints{}); err == nil {
+		t.Error("Expected error for unexpected object")
+	}
+}
+
+func TestEndpointsFromSlices(t *testing.T) {
+	slices := []*EndpointSlice{
+		{Name: "svc1-b", Namespace: "testns", ServiceName: "svc1", Index: "svc1.testns", IndexIP: []string{"10.0.0.2"},
+			Addresses: []EndpointAddress{{IP: "10.0.0.2"}}, Ports: []EndpointPort{{Port: 80}}},
+		{Name: "svc2-a", Namespace: "testns", ServiceName: "svc2", Index: "svc2.testns", IndexIP: []string{"10.0.1.1"},
+			Addresses: []EndpointAddress{{IP: "10.0.1.1"}}, Ports: []EndpointPort{{Port: 53}}},
+		{Name: "svc1-a", Namespace: "testns", ServiceName: "svc1", Index: "svc1.testns", IndexIP: []string{"10.0.0.1"},
+			Addresses: []EndpointAddress{{IP: "10.0.0.1"}}, NotReadyAddresses: []EndpointAddress{{IP: "10.0.0.9"}},
+			Ports: []EndpointPort{{Port: 80}}},
+		// slices without a service are ignored
+		{Name: "orphan", Namespace: "testns", Addresses: []EndpointAddress{{IP: "10.0.2.1"}}},
+	}
+
+	eps := EndpointsFromSlices(slices)
+	if len(eps) != 2 {
+		t.Fatalf("Expected endpoints of 2 services, got %d", len(eps))
+	}
+	svc1, svc2 := eps[0], eps[1]
+	if svc1.Name != "svc1" || svc1.Namespace != "testns" || svc1.Index != "svc1.testns" || svc2.Name != "svc2" {
+		t.Fatalf("Expected endpoints of svc1 and svc2, got %+v and %+v", svc1, svc2)
+	}
+	// a subset per slice, ordered by slice name
+	if len(svc1.Subsets) != 2 || svc1.Subsets[0].Addresses[0].IP != "10.0.0.1" || svc1.Subsets[1].Addresses[0].IP != "10.0.0.2" {
+		t.Errorf("Expected a subset per slice of svc1 in name order, got %+v", svc1.Subsets)
+	}
+	if !reflect.DeepEqual(svc1.Subsets[0].NotReadyAddresses, []EndpointAddress{{IP: "10.0.0.9"}}) {
+		t.Errorf("Expected not ready addresses to be kept, got %+v", svc1.Subsets[0].NotReadyAddresses)
+	}
+	if !reflect.DeepEqual(svc1.IndexIP, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("Expected indexed IPs of both slices, got %v", svc1.IndexIP)
+	}
+	if slices[0].Name != "svc1-b" {
+		t.Error("Expected slices not to be reordered")
+	}
+}
@@ -19,15 +19,11 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/tools/cache"
 )
 
 // ToFunc converts one empty interface to another.
 type ToFunc func(interface{}) (interface{}, error)
 
-// ProcessorBuilder returns function to process cache events.
-type ProcessorBuilder func(cache.Indexer, cache.ResourceEventHandler) cache.ProcessFunc
-
 // Empty is an empty struct.
 type Empty struct{}
 
@@ -2,6 +2,7 @@ package object
 
 import (
 	"fmt"
+	"net"
 
 	api "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -19,6 +20,14 @@ type Service struct {
 	ExternalName string
 	Ports        []api.ServicePort
 
+	// ClusterIPs are the cluster IPs of the service, one per IP family of a dual-stack service, and IPFamilies
+	// their IP families. They are empty for headless and ExternalName services.
+	ClusterIPs []string
+	IPFamilies []api.IPFamily
+
+	// PublishNotReadyAddresses is set if the not ready endpoints of the service are served.
+	PublishNotReadyAddresses bool
+
 	// ExternalIPs we may want to export.
 	ExternalIPs []string
 
@@ -49,9 +58,13 @@ func toService(skipCleanup bool, svc *api.Service) *Service {
 		Type:         svc.Spec.Type,
 		ExternalName: svc.Spec.ExternalName,
 
+		PublishNotReadyAddresses: svc.Spec.PublishNotReadyAddresses,
+
 		ExternalIPs: make([]string, len(svc.Status.LoadBalancer.Ingress)+len(svc.Spec.ExternalIPs)),
 	}
 
+	s.ClusterIPs, s.IPFamilies = clusterIPs(svc)
+
 	if len(svc.Spec.Ports) == 0 {
 		// Add sentinel if there are no ports.
 		s.Ports = []api.ServicePort{{Port: -1}}
@@ -77,6 +90,49 @@ func toService(skipCleanup bool, svc *api.Service) *Service {
 	return s
 }
 
+// clusterIPs returns the cluster IPs of svc and their IP families. Services created before the API had
+// spec.clusterIPs may only set spec.clusterIP, and the families are derived from the IPs if spec.ipFamilies is not
+// set.
+func clusterIPs(svc *api.Service) ([]string, []api.IPFamily) {
+	ips := svc.Spec.ClusterIPs
+	if len(ips) == 0 {
+		ips = []string{svc.Spec.ClusterIP}
+	}
+	var (
+		cips     []string
+		families []api.IPFamily
+	)
+	for i, cip := range ips {
+		ip := net.ParseIP(cip)
+		if ip == nil {
+			// headless services have the cluster IP "None"
+			continue
+		}
+		family := api.IPv4Protocol
+		if ip.To4() == nil {
+			family = api.IPv6Protocol
+		}
+		if i < len(svc.Spec.IPFamilies) {
+			family = svc.Spec.IPFamilies[i]
+		}
+		cips = append(cips, cip)
+		families = append(families, family)
+	}
+	return cips, families
+}
+
+// ClusterIPList returns the cluster IPs of the service: its ClusterIPs, or its ClusterIP for a service built without
+// them. Headless and ExternalName services have none.
+func (s *Service) ClusterIPList() []string {
+	if len(s.ClusterIPs) > 0 {
+		return s.ClusterIPs
+	}
+	if net.ParseIP(s.ClusterIP) == nil {
+		return nil
+	}
+	return []string{s.ClusterIP}
+}
+
 var _ runtime.Object = &Service{}
 
 // DeepCopyObject implements the ObjectKind interface.
@@ -87,11 +143,17 @@ func (s *Service) DeepCopyObject() runtime.Object {
 		Namespace:    s.Namespace,
 		Index:        s.Index,
 		ClusterIP:    s.ClusterIP,
+		ClusterIPs:   make([]string, len(s.ClusterIPs)),
+		IPFamilies:   make([]api.IPFamily, len(s.IPFamilies)),
 		Type:         s.Type,
 		ExternalName: s.ExternalName,
 		Ports:        make([]api.ServicePort, len(s.Ports)),
 		ExternalIPs:  make([]string, len(s.ExternalIPs)),
+
+		PublishNotReadyAddresses: s.PublishNotReadyAddresses,
 	}
+	copy(s1.ClusterIPs, s.ClusterIPs)
+	copy(s1.IPFamilies, s.IPFamilies)
 	copy(s1.Ports, s.Ports)
 	copy(s1.ExternalIPs, s.ExternalIPs)
 	return s1
@@ -0,0 +1,69 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+)
+
+func TestClusterIPs(t *testing.T) {
+	tests := []struct {
+		spec     api.ServiceSpec
+		ips      []string
+		families []api.IPFamily
+	}{
+		{
+			api.ServiceSpec{
+				ClusterIP:  "10.0.0.1",
+				ClusterIPs: []string{"10.0.0.1", "1234:abcd::1"},
+				IPFamilies: []api.IPFamily{api.IPv4Protocol, api.IPv6Protocol},
+			},
+			[]string{"10.0.0.1", "1234:abcd::1"}, []api.IPFamily{api.IPv4Protocol, api.IPv6Protocol},
+		},
+		// the families are derived from the IPs if not set
+		{
+			api.ServiceSpec{ClusterIP: "1234:abcd::1", ClusterIPs: []string{"1234:abcd::1", "10.0.0.1"}},
+			[]string{"1234:abcd::1", "10.0.0.1"}, []api.IPFamily{api.IPv6Protocol, api.IPv4Protocol},
+		},
+		// services created before spec.clusterIPs only set spec.clusterIP
+		{
+			api.ServiceSpec{ClusterIP: "10.0.0.1"},
+			[]string{"10.0.0.1"}, []api.IPFamily{api.IPv4Protocol},
+		},
+		{
+			api.ServiceSpec{ClusterIP: api.ClusterIPNone, ClusterIPs: []string{api.ClusterIPNone}},
+			nil, nil,
+		},
+		{api.ServiceSpec{Type: api.ServiceTypeExternalName}, nil, nil},
+	}
+
+	for i, tc := range tests {
+		ips, families := clusterIPs(&api.Service{Spec: tc.spec})
+		if !reflect.DeepEqual(ips, tc.ips) {
+			t.Errorf("Test %d: expected cluster IPs %v, got %v", i, tc.ips, ips)
+		}
+		if !reflect.DeepEqual(families, tc.families) {
+			t.Errorf("Test %d: expected IP families %v, got %v", i, tc.families, families)
+		}
+	}
+}
+
+func TestClusterIPList(t *testing.T) {
+	tests := []struct {
+		svc *Service
+		ips []string
+	}{
+		{&Service{ClusterIP: "10.0.0.1", ClusterIPs: []string{"10.0.0.1", "1234:abcd::1"}}, []string{"10.0.0.1", "1234:abcd::1"}},
+		// services built without cluster IPs have their cluster IP
+		{&Service{ClusterIP: "10.0.0.1"}, []string{"10.0.0.1"}},
+		{&Service{ClusterIP: api.ClusterIPNone}, nil},
+		{&Service{Type: api.ServiceTypeExternalName}, nil},
+	}
+
+	for i, tc := range tests {
+		if ips := tc.svc.ClusterIPList(); !reflect.DeepEqual(ips, tc.ips) {
+			t.Errorf("Test %d: expected cluster IPs %v, got %v", i, tc.ips, ips)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package k8sapi
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ConvertFunc converts an object listed or watched by an Informer into the object held in its store, e.g. a compact
+// struct holding only the fields a plugin needs. The returned object must implement metav1.Object, so it can be keyed
+// by namespace and name.
+type ConvertFunc func(obj interface{}) (interface{}, error)
+
+// NewProcessor returns the cache.ProcessFunc of an Informer holding the objects converted by convert in store, and
+// passing its events to h. It is based on the Process function from cache.NewIndexerInformer, except:
+//
+//   - objects are converted before they are stored, or stored as they are if convert is nil. Objects that fail to
+//     convert are not stored, and a stored object whose update fails to convert is kept.
+//   - deleted objects are removed by key, so that h is passed the stored object, even when the deleted object does
+//     not convert.
+//   - observe, if not nil, is called with each added, updated or deleted object before it is converted, e.g. to record
+//     the latency of changes.
+func NewProcessor(store cache.Indexer, convert ConvertFunc, observe func(metav1.Object), h cache.ResourceEventHandler) cache.ProcessFunc {
+	return func(obj interface{}) error {
+		for _, d := range obj.(cache.Deltas) {
+			if m, ok := d.Object.(metav1.Object); ok && observe != nil {
+				observe(m)
+			}
+			switch d.Type {
+			case cache.Sync, cache.Added, cache.Updated:
+				obj := d.Object
+				if convert != nil {
+					var err error
+					if obj, err = convert(obj); err != nil {
+						log.Debugf("Not storing object: %v", err)
+						continue
+					}
+				}
+				if old, exists, err := store.Get(obj); err == nil && exists {
+					if err := store.Update(obj); err != nil {
+						return err
+					}
+					h.OnUpdate(old, obj)
+				} else {
+					if err := store.Add(obj); err != nil {
+						return err
+					}
+					h.OnAdd(obj)
+				}
+			case cache.Deleted:
+				key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(d.Object)
+				if err != nil {
+					return err
+				}
+				old, exists, err := store.GetByKey(key)
+				if err != nil || !exists {
+					continue
+				}
+				if err := store.Delete(old); err != nil {
+					return err
+				}
+				h.OnDelete(old)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+package k8sapi
+
+import (
+	"errors"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// compactService is a Service converted by toCompactService.
+type compactService struct {
+	metav1.ObjectMeta
+	ClusterIP string
+}
+
+func toCompactService(obj interface{}) (interface{}, error) {
+	svc, ok := obj.(*api.Service)
+	if !ok {
+		return nil, errors.New("unexpected object")
+	}
+	if svc.Spec.ClusterIP == "" {
+		return nil, errors.New("no cluster IP")
+	}
+	return &compactService{ObjectMeta: svc.ObjectMeta, ClusterIP: svc.Spec.ClusterIP}, nil
+}
+
+func TestNewProcessor(t *testing.T) {
+	var observed []string
+	var deleted interface{}
+	store := cache.NewIndexer(cache.DeletionHandlingMetaNamespaceKeyFunc, cache.Indexers{})
+	observe := func(m metav1.Object) { observed = append(observed, m.GetName()) }
+	process := NewProcessor(store, toCompactService, observe, cache.ResourceEventHandlerFuncs{DeleteFunc: func(obj interface{}) { deleted = obj }})
+
+	svc := &api.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "testns", Name: "svc1"}, Spec: api.ServiceSpec{ClusterIP: "10.0.0.1"}}
+	if err := process(cache.Deltas{{Type: cache.Added, Object: svc}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// an update that fails to convert keeps the stored object
+	failed := svc.DeepCopy()
+	failed.Spec.ClusterIP = ""
+	if err := process(cache.Deltas{{Type: cache.Updated, Object: failed}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	obj, exists, _ := store.GetByKey("testns/svc1")
+	if !exists || obj.(*compactService).ClusterIP != "10.0.0.1" {
+		t.Errorf("Expected stored service to be kept, got %+v", obj)
+	}
+
+	// deletes remove the stored object, even when the deleted object does not convert
+	if err := process(cache.Deltas{{Type: cache.Deleted, Object: cache.DeletedFinalStateUnknown{Key: "testns/svc1", Obj: failed}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, exists, _ := store.GetByKey("testns/svc1"); exists {
+		t.Error("Expected service to be deleted")
+	}
+	if _, ok := deleted.(*compactService); !ok {
+		t.Errorf("Expected delete event with the stored object, got %T", deleted)
+	}
+	if len(observed) != 2 {
+		t.Errorf("Expected 2 observed objects, got %v", observed)
+	}
+
+	// without a ConvertFunc, the objects are stored as they are
+	process = NewProcessor(store, nil, nil, cache.ResourceEventHandlerFuncs{})
+	if err := process(cache.Deltas{{Type: cache.Added, Object: svc}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if obj, _, _ := store.GetByKey("testns/svc1"); obj != svc {
+		t.Errorf("Expected API object to be stored, got %+v", obj)
+	}
+}
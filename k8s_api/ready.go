@@ -0,0 +1,4 @@
+package k8sapi
+
+// Ready implements the ready.Readiness interface. It reports ready once all Informers have synced.
+func (k *KubeAPI) Ready() bool { return k.APIConn != nil && k.APIConn.HasSynced() }
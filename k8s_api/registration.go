@@ -0,0 +1,218 @@
+package k8sapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"k8s.io/client-go/tools/cache"
+)
+
+// registration is an Informer registered by one or more plugins.
+type registration struct {
+	fn    InformerFunc
+	owner string
+
+	// objType and typeOwner are the declared object type and the first plugin that declared it.
+	objType   reflect.Type
+	typeOwner string
+
+	indexers cache.Indexers
+
+	// declared is set if the owner declared the Informer's Registration, and options are its Options.
+	declared bool
+	options  string
+
+	// subscribers are the plugins subscribed to the Informer's events, and their handlers.
+	subscribers []string
+	handlers    []cache.ResourceEventHandler
+
+	// consumers are the plugins registering, adding indexers or subscribing to the Informer, including its owner.
+	consumers []string
+}
+
+// typedIndexer is the store of an Informer with a declared object type, as passed to SetIndexer.
+type typedIndexer struct {
+	cache.Indexer
+	objType reflect.Type
+}
+
+// ObjectType implements TypedLister.
+func (t *typedIndexer) ObjectType() reflect.Type { return t.objType }
+
+// registerInformers collects the Informers registered by all plugins implementing APIWatcher. The first plugin
+// (per plugin execution order) registering an Informer name provides its InformerFunc. An error is returned if
+// plugins declare different object types for the same Informer name, or add indexers or subscribe to an unknown
+// Informer.
+func registerInformers(plugins []plugin.Handler) (map[string]*registration, error) {
+	regs := make(map[string]*registration)
+	for _, pl := range plugins {
+		w, ok := pl.(APIWatcher)
+		if !ok {
+			continue
+		}
+		var decls map[string]Registration
+		if r, ok := pl.(Registrar); ok {
+			decls = r.Registrations()
+		}
+		for n, f := range w.Informers() {
+			reg, ok := regs[n]
+			if !ok {
+				reg = &registration{fn: f, owner: pl.Name(), indexers: cache.Indexers{}}
+				regs[n] = reg
+			}
+			reg.consume(pl.Name())
+			decl, ok := decls[n]
+			if ok && reg.owner == pl.Name() {
+				reg.declared, reg.options = true, decl.Options
+			}
+			if !ok {
+				if reg.owner != pl.Name() {
+					log.Warningf("Plugin %q registers informer %q without declaring its type, using the informer of plugin %q", pl.Name(), n, reg.owner)
+				}
+				continue
+			}
+			if err := reg.declare(pl.Name(), n, decl); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Add indexers from plugins that extend Informers they may not own
+	for _, pl := range plugins {
+		p, ok := pl.(IndexProvider)
+		if !ok {
+			continue
+		}
+		for n, indexers := range p.Indexers() {
+			reg, ok := regs[n]
+			if !ok {
+				return nil, fmt.Errorf("plugin %q adds indexers to informer %q, but no plugin registers it", pl.Name(), n)
+			}
+			if err := reg.declare(pl.Name(), n, Registration{Indexers: indexers}); err != nil {
+				return nil, err
+			}
+			reg.consume(pl.Name())
+		}
+	}
+	// Add subscriptions to Informers the plugins may not own
+	for _, pl := range plugins {
+		s, ok := pl.(Subscriber)
+		if !ok {
+			continue
+		}
+		for n, h := range s.Subscriptions() {
+			reg, ok := regs[n]
+			if !ok {
+				return nil, fmt.Errorf("plugin %q subscribes to informer %q, but no plugin registers it", pl.Name(), n)
+			}
+			reg.subscribers = append(reg.subscribers, pl.Name())
+			reg.handlers = append(reg.handlers, h)
+			reg.consume(pl.Name())
+		}
+	}
+	return regs, nil
+}
+
+// declare checks that decl is compatible with the registration, and merges its indexers.
+func (r *registration) declare(owner, name string, decl Registration) error {
+	if decl.Object != nil {
+		t := reflect.TypeOf(decl.Object)
+		switch {
+		case r.objType == nil:
+			r.objType, r.typeOwner = t, owner
+		case r.objType != t:
+			return fmt.Errorf("plugin %q registers informer %q for type %v, but plugin %q registers it for type %v", owner, name, t, r.typeOwner, r.objType)
+		}
+	}
+	for idx, f := range decl.Indexers {
+		// the first plugin to declare an index name provides its IndexFunc
+		if _, ok := r.indexers[idx]; !ok {
+			r.indexers[idx] = f
+		}
+	}
+	return nil
+}
+
+// consume adds the plugin to the consumers of the registration, if missing.
+func (r *registration) consume(name string) {
+	for _, c := range r.consumers {
+		if c == name {
+			return
+		}
+	}
+	r.consumers = append(r.consumers, name)
+}
+
+// addIndexers adds the indexers of the registration that are missing from the Informer's store.
+func (r *registration) addIndexers(name string, inf *Informer) error {
+	missing := cache.Indexers{}
+	idx, ok := inf.Lister.(cache.Indexer)
+	for n, f := range r.indexers {
+		if ok {
+			if _, exists := idx.GetIndexers()[n]; exists {
+				continue
+			}
+		}
+		missing[n] = f
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("informer %q registered by plugin %q does not support indexers", name, r.owner)
+	}
+	return idx.AddIndexers(missing)
+}
+
+// eventFanout returns the fanout delivering the Informer's events to subscribers, or nil if there are none.
+func (r *registration) eventFanout() *eventFanout {
+	if len(r.handlers) == 0 {
+		return nil
+	}
+	return newEventFanout(r.handlers)
+}
+
+// checkSubscriptions returns an error if plugins subscribe to an Informer that does not deliver events.
+func (r *registration) checkSubscriptions(name string, events *eventRouter) error {
+	if len(r.subscribers) == 0 || events.used {
+		return nil
+	}
+	return fmt.Errorf("plugin %q subscribes to informer %q, but the informer registered by plugin %q does not support subscriptions", r.subscribers[0], name, r.owner)
+}
+
+// orderedHandlers sorts plugins in plugin execution order, i.e. the order of directives in plugin.cfg.
+func orderedHandlers(plugins []plugin.Handler) []plugin.Handler {
+	order := make(map[string]int, len(dnsserver.Directives))
+	for i, d := range dnsserver.Directives {
+		order[d] = i
+	}
+	sorted := make([]plugin.Handler, len(plugins))
+	copy(sorted, plugins)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].Name()] < order[sorted[j].Name()]
+	})
+	return sorted
+}
+
+// usedInformers returns the names of the Informers a plugin registers, adds indexers or subscribes to.
+func usedInformers(pl plugin.Handler) []string {
+	var names []string
+	if w, ok := pl.(APIWatcher); ok {
+		for n := range w.Informers() {
+			names = append(names, n)
+		}
+	}
+	if p, ok := pl.(IndexProvider); ok {
+		for n := range p.Indexers() {
+			names = append(names, n)
+		}
+	}
+	if s, ok := pl.(Subscriber); ok {
+		for n := range s.Subscriptions() {
+			names = append(names, n)
+		}
+	}
+	return names
+}
@@ -0,0 +1,144 @@
+package k8sapi
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/miekg/dns"
+	api "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type testWatcher struct {
+	name      string
+	informers map[string]InformerFunc
+	regs      map[string]Registration
+}
+
+func (w testWatcher) ServeDNS(ctx context.Context, rw dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return dns.RcodeSuccess, nil
+}
+func (w testWatcher) Name() string                                   { return w.name }
+func (w testWatcher) Informers() map[string]InformerFunc             { return w.informers }
+func (w testWatcher) Registrations() map[string]Registration         { return w.regs }
+func (w testWatcher) SetIndexer(string, cache.KeyListerGetter) error { return nil }
+func (w testWatcher) SetHasSynced(HasSyncedFunc)                     {}
+
+func testInformerFunc(indexers cache.Indexers) InformerFunc {
+	return func(context.Context, kubernetes.Interface, InformerOptions) *Informer {
+		return &Informer{Lister: cache.NewIndexer(cache.MetaNamespaceKeyFunc, indexers)}
+	}
+}
+
+func testIndexFunc(obj interface{}) ([]string, error) { return nil, nil }
+
+func TestRegisterInformersConflict(t *testing.T) {
+	plugins := []plugin.Handler{
+		testWatcher{
+			name:      "first",
+			informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{})},
+			regs:      map[string]Registration{"pod": {Object: &api.Pod{}}},
+		},
+		testWatcher{
+			name:      "second",
+			informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{})},
+			regs:      map[string]Registration{"pod": {Object: &api.Service{}}},
+		},
+	}
+	_, err := registerInformers(plugins)
+	if err == nil {
+		t.Fatal("expected error for incompatible informers")
+	}
+	if !strings.Contains(err.Error(), `plugin "second" registers informer "pod"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRegisterInformersMerge(t *testing.T) {
+	plugins := []plugin.Handler{
+		testWatcher{
+			name:      "first",
+			informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{"a": testIndexFunc})},
+			regs:      map[string]Registration{"pod": {Object: &api.Pod{}, Indexers: cache.Indexers{"a": testIndexFunc}}},
+		},
+		testWatcher{
+			name:      "second",
+			informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{})},
+			regs:      map[string]Registration{"pod": {Object: &api.Pod{}, Indexers: cache.Indexers{"a": testIndexFunc, "b": testIndexFunc}}},
+		},
+	}
+	regs, err := registerInformers(plugins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reg := regs["pod"]
+	if reg.owner != "first" {
+		t.Errorf("expected informer of plugin %q, got %q", "first", reg.owner)
+	}
+	inf := reg.fn(context.Background(), nil, InformerOptions{})
+	if err := reg.addIndexers("pod", inf); err != nil {
+		t.Fatalf("unexpected error adding indexers: %v", err)
+	}
+	indexers := inf.Lister.(cache.Indexer).GetIndexers()
+	for _, n := range []string{"a", "b"} {
+		if _, ok := indexers[n]; !ok {
+			t.Errorf("expected index %q in store", n)
+		}
+	}
+}
+
+type testIndexProvider struct {
+	testWatcher
+	indexers map[string]cache.Indexers
+}
+
+func (p testIndexProvider) Indexers() map[string]cache.Indexers { return p.indexers }
+
+func TestRegisterInformersIndexProvider(t *testing.T) {
+	owner := testWatcher{
+		name:      "owner",
+		informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{})},
+	}
+	consumer := testIndexProvider{
+		testWatcher: testWatcher{name: "consumer"},
+		indexers:    map[string]cache.Indexers{"pod": {"c": testIndexFunc}},
+	}
+
+	regs, err := registerInformers([]plugin.Handler{consumer, owner})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	inf := regs["pod"].fn(context.Background(), nil, InformerOptions{})
+	if err := regs["pod"].addIndexers("pod", inf); err != nil {
+		t.Fatalf("unexpected error adding indexers: %v", err)
+	}
+	if _, ok := inf.Lister.(cache.Indexer).GetIndexers()["c"]; !ok {
+		t.Error("expected index \"c\" in store")
+	}
+
+	// indexers for an informer nobody registers
+	consumer.indexers = map[string]cache.Indexers{"service": {"c": testIndexFunc}}
+	if _, err := registerInformers([]plugin.Handler{consumer, owner}); err == nil {
+		t.Error("expected error adding indexers to unknown informer")
+	}
+}
+
+func TestUsedInformers(t *testing.T) {
+	pl := testIndexProvider{
+		testWatcher: testWatcher{
+			name:      "consumer",
+			informers: map[string]InformerFunc{"service": testInformerFunc(cache.Indexers{})},
+		},
+		indexers: map[string]cache.Indexers{"pod": {"c": testIndexFunc}},
+	}
+	names := usedInformers(pl)
+	sort.Strings(names)
+	if !reflect.DeepEqual(names, []string{"pod", "service"}) {
+		t.Errorf("expected informers [pod service], got %v", names)
+	}
+}
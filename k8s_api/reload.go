@@ -0,0 +1,180 @@
+package k8sapi
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+)
+
+// reloadInterval is how often the files a connection is configured from are checked for changes.
+var reloadInterval = 10 * time.Second
+
+// reloadingTransport is the http.RoundTripper of the clients of a connection. It delegates to a transport built
+// from the connection's rest.Config, which is rebuilt when the kubeconfig or certificate files change, so that
+// credentials are rotated without rebuilding the clients and Informers.
+type reloadingTransport struct {
+	conn *Connection
+
+	lock sync.RWMutex
+	rt   http.RoundTripper
+	host *url.URL // host replaces the host of requests if it changed since the clients were built
+
+	initialHost string
+	sums        map[string][32]byte
+}
+
+func newReloadingTransport(conn *Connection, config *rest.Config) (*reloadingTransport, error) {
+	rt, err := rest.TransportFor(config)
+	if err != nil {
+		return nil, err
+	}
+	t := &reloadingTransport{conn: conn, rt: rt, initialHost: config.Host}
+	t.sums = fileSums(watchedFiles(conn, config))
+	return t, nil
+}
+
+// clientConfig returns a copy of config that uses the transport for TLS and authentication.
+func (t *reloadingTransport) clientConfig(config *rest.Config) *rest.Config {
+	cc := rest.CopyConfig(config)
+	cc.TLSClientConfig = rest.TLSClientConfig{}
+	cc.BearerToken, cc.BearerTokenFile = "", ""
+	cc.Username, cc.Password = "", ""
+	cc.AuthProvider, cc.AuthConfigPersister = nil, nil
+	cc.ExecProvider = nil
+	cc.Impersonate = rest.ImpersonationConfig{}
+	cc.WrapTransport, cc.Dial, cc.Proxy = nil, nil, nil
+	cc.Transport = t
+	return cc
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *reloadingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.RLock()
+	rt, host := t.rt, t.host
+	t.lock.RUnlock()
+	if host != nil {
+		req = req.Clone(req.Context())
+		req.URL.Scheme, req.URL.Host = host.Scheme, host.Host
+		req.Host = ""
+	}
+	return rt.RoundTrip(req)
+}
+
+// run checks the watched files for changes until stopCh is closed.
+func (t *reloadingTransport) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			t.reloadIfChanged()
+		}
+	}
+}
+
+// reloadIfChanged rebuilds the transport if any watched file changed. If the new settings cannot be loaded,
+// the current transport is kept until the files change again.
+func (t *reloadingTransport) reloadIfChanged() bool {
+	t.lock.RLock()
+	files := make([]string, 0, len(t.sums))
+	changed := false
+	for f, sum := range t.sums {
+		files = append(files, f)
+		if s := fileSum(f); s != sum {
+			changed = true
+		}
+	}
+	t.lock.RUnlock()
+	if !changed {
+		return false
+	}
+
+	config, err := t.conn.getClientConfig()
+	if err == nil {
+		var rt http.RoundTripper
+		if rt, err = rest.TransportFor(config); err == nil {
+			t.update(rt, config)
+			log.Infof("Reloaded connection settings")
+			return true
+		}
+	}
+	log.Errorf("Failed to reload connection settings: %v", err)
+	t.lock.Lock()
+	t.sums = fileSums(files)
+	t.lock.Unlock()
+	return false
+}
+
+func (t *reloadingTransport) update(rt http.RoundTripper, config *rest.Config) {
+	var host *url.URL
+	if config.Host != t.initialHost {
+		if u, err := url.Parse(config.Host); err == nil && u.Host != "" {
+			host = u
+		} else {
+			log.Warningf("Ignoring API server %q of reloaded connection settings, a reload is needed to use it", config.Host)
+		}
+	}
+	sums := fileSums(watchedFiles(t.conn, config))
+
+	t.lock.Lock()
+	old := t.rt
+	t.rt, t.host, t.sums = rt, host, sums
+	t.lock.Unlock()
+
+	closeIdleConnections(old)
+}
+
+// watchedFiles returns the files the connection settings are read from.
+func watchedFiles(conn *Connection, config *rest.Config) []string {
+	var files []string
+	for _, f := range []string{
+		conn.kubeconfig, conn.APICertAuth, conn.APIClientCert, conn.APIClientKey,
+		config.TLSClientConfig.CAFile, config.TLSClientConfig.CertFile, config.TLSClientConfig.KeyFile,
+		config.BearerTokenFile,
+	} {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// fileSums returns the checksums of the files.
+func fileSums(files []string) map[string][32]byte {
+	sums := make(map[string][32]byte, len(files))
+	for _, f := range files {
+		sums[f] = fileSum(f)
+	}
+	return sums
+}
+
+// fileSum returns the checksum of the file, or a zero checksum if it cannot be read.
+func fileSum(file string) [32]byte {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return [32]byte{}
+	}
+	return sha256.Sum256(b)
+}
+
+// closeIdleConnections closes the idle connections of the transport wrapped by rt, if any.
+func closeIdleConnections(rt http.RoundTripper) {
+	for {
+		w, ok := rt.(utilnet.RoundTripperWrapper)
+		if !ok {
+			break
+		}
+		rt = w.WrappedRoundTripper()
+	}
+	if c, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,169 @@
+package k8sapi
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+    insecure-skip-tls-verify: true
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: %s
+current-context: test
+`
+
+func TestReloadingTransport(t *testing.T) {
+	servers := make([]*httptest.Server, 2)
+	tokens := make(chan string, 2)
+	for i := range servers {
+		i := i
+		servers[i] = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokens <- fmt.Sprintf("%d %s", i, r.Header.Get("Authorization"))
+		}))
+		defer servers[i].Close()
+	}
+
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kubeconfig")
+	write := func(server, token string) {
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(testKubeconfig, server, token)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(servers[0].URL, "a")
+
+	conn := &Connection{
+		ClientConfig: newKubeconfigClientConfig(path, "test"),
+		kubeconfig:   path,
+		kubecontext:  "test",
+	}
+	config, err := conn.getClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newReloadingTransport(conn, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tr}
+	get := func() string {
+		resp, err := client.Get(servers[0].URL + "/api")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return <-tokens
+	}
+
+	if got := get(); got != "0 Bearer a" {
+		t.Errorf("Expected request to server 0 with token a, got %q", got)
+	}
+	if tr.reloadIfChanged() {
+		t.Error("Expected no reload of unchanged files")
+	}
+
+	write(servers[0].URL, "b")
+	if !tr.reloadIfChanged() {
+		t.Fatal("Expected reload of changed kubeconfig")
+	}
+	if got := get(); got != "0 Bearer b" {
+		t.Errorf("Expected request to server 0 with token b, got %q", got)
+	}
+
+	write(servers[1].URL, "c")
+	if !tr.reloadIfChanged() {
+		t.Fatal("Expected reload of changed kubeconfig")
+	}
+	if got := get(); got != "1 Bearer c" {
+		t.Errorf("Expected request to server 1 with token c, got %q", got)
+	}
+
+	// an invalid kubeconfig keeps the current settings
+	if err := ioutil.WriteFile(path, []byte("invalid"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if tr.reloadIfChanged() {
+		t.Error("Expected no reload of invalid kubeconfig")
+	}
+	if got := get(); got != "1 Bearer c" {
+		t.Errorf("Expected request to server 1 with token c, got %q", got)
+	}
+}
+
+func TestReloadingTransportTokenFile(t *testing.T) {
+	tokens := make(chan string, 1)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens <- r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.crt")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(ca, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "token")
+	write := func(token string) {
+		if err := ioutil.WriteFile(path, []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a")
+
+	conn := &Connection{APIServer: server.URL, APICertAuth: ca, tokenFile: path}
+	config, err := conn.getClientConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newReloadingTransport(conn, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tr}
+	get := func() string {
+		resp, err := client.Get(server.URL + "/api")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return <-tokens
+	}
+
+	if got := get(); got != "Bearer a" {
+		t.Errorf("Expected token a, got %q", got)
+	}
+	write("b")
+	if !tr.reloadIfChanged() {
+		t.Fatal("Expected reload of rotated token file")
+	}
+	if got := get(); got != "Bearer b" {
+		t.Errorf("Expected token b, got %q", got)
+	}
+}
@@ -0,0 +1,209 @@
+package k8sapi
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// reusable holds the apiControls of running k8s_api instances, so that after a Corefile reload, the new k8s_api
+// instance can take over the running apiControls of the instance it replaces, if their configuration is unchanged.
+//
+// Caddy runs the OnRestart callbacks of the old instance, then the OnStartup callbacks of the new instance, and then
+// either the OnShutdown callbacks (if the new instance started) or the OnRestartFailed callbacks of the old instance.
+var reusable = &controlRegistry{controls: make(map[*apiControl]struct{})}
+
+type controlRegistry struct {
+	sync.Mutex
+	controls map[*apiControl]struct{}
+}
+
+// reuseState tracks the k8s_api instance owning an apiControl.
+type reuseState struct {
+	owner *KubeAPI
+
+	// restarting is set while the owner is being replaced by a Corefile reload.
+	restarting bool
+
+	// previous is the owner an apiControl was taken over from, and the event handlers of its plugins, kept until
+	// the restart of the previous owner completes or fails.
+	previous *handover
+}
+
+type handover struct {
+	owner    *KubeAPI
+	bindings map[string]eventBinding
+}
+
+type eventBinding struct {
+	handler cache.ResourceEventHandler
+	fanout  *eventFanout
+}
+
+// add registers apiControls owned by k.
+func (r *controlRegistry) add(k *KubeAPI, controls ...*apiControl) {
+	r.Lock()
+	defer r.Unlock()
+	for _, dns := range controls {
+		dns.reuse = reuseState{owner: k}
+		r.controls[dns] = struct{}{}
+	}
+}
+
+// adopt transfers an apiControl with the key, whose owner is restarting, to k.
+func (r *controlRegistry) adopt(key string, k *KubeAPI) *apiControl {
+	if key == "" {
+		return nil
+	}
+	r.Lock()
+	defer r.Unlock()
+	for dns := range r.controls {
+		if dns.key != key || !dns.reuse.restarting || dns.reuse.owner == k {
+			continue
+		}
+		dns.reuse = reuseState{
+			owner:    k,
+			previous: &handover{owner: dns.reuse.owner, bindings: make(map[string]eventBinding)},
+		}
+		return dns
+	}
+	return nil
+}
+
+// route routes the events of an Informer of an adopted apiControl to the plugins of its new owner.
+func (r *controlRegistry) route(dns *apiControl, name string, h cache.ResourceEventHandler, f *eventFanout) {
+	r.Lock()
+	defer r.Unlock()
+	if f != nil {
+		f.start()
+	}
+	dns.lock.RLock()
+	oldH, oldF := dns.routers[name].route(h, f)
+	dns.lock.RUnlock()
+	if dns.reuse.previous != nil {
+		dns.reuse.previous.bindings[name] = eventBinding{handler: oldH, fanout: oldF}
+	}
+}
+
+// restart marks the apiControls of k as reusable.
+func (r *controlRegistry) restart(k *KubeAPI) {
+	r.Lock()
+	defer r.Unlock()
+	for dns := range r.controls {
+		if dns.reuse.owner == k {
+			dns.reuse.restarting = true
+		}
+	}
+}
+
+// restartFailed returns the apiControls adopted from k, and routes their events back to the plugins of k.
+func (r *controlRegistry) restartFailed(k *KubeAPI) {
+	r.Lock()
+	defer r.Unlock()
+	for dns := range r.controls {
+		if p := dns.reuse.previous; p != nil && p.owner == k {
+			dns.lock.RLock()
+			for name, b := range p.bindings {
+				if _, f := dns.routers[name].route(b.handler, b.fanout); f != nil {
+					f.stop()
+				}
+			}
+			dns.lock.RUnlock()
+			dns.reuse = reuseState{owner: k}
+		}
+		if dns.reuse.owner == k {
+			dns.reuse.restarting = false
+		}
+	}
+}
+
+// shutdown stops the apiControls owned by k, and releases the event handlers of apiControls adopted from k. An
+// apiControl k adopted from an instance still restarting is kept running, as it goes back to that instance if k
+// failed to start.
+func (r *controlRegistry) shutdown(k *KubeAPI) error {
+	r.Lock()
+	defer r.Unlock()
+	var err error
+	for dns := range r.controls {
+		if p := dns.reuse.previous; p != nil && p.owner == k {
+			for _, b := range p.bindings {
+				if b.fanout != nil {
+					b.fanout.stop()
+				}
+			}
+			dns.reuse.previous = nil
+		}
+		if dns.reuse.owner != k || dns.reuse.previous != nil {
+			continue
+		}
+		delete(r.controls, dns)
+		if e := dns.Stop(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// controlKey returns a key identifying the configuration of the apiControl of a cluster with the registered
+// Informers, or an empty key if the apiControl cannot be reused.
+func (k *KubeAPI) controlKey(cluster string, conn *Connection, regs map[string]*registration) string {
+	if conn.ClientConfig != nil && conn.kubeconfig == "" {
+		// the ClientConfig was not loaded from a kubeconfig file, so it cannot be compared
+		return ""
+	}
+	opts := k.informerOptions()
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "cluster=%q endpoint=%q tls=%q,%q,%q kubeconfig=%q,%q\n", cluster, conn.APIServer,
+		conn.APIClientCert, conn.APIClientKey, conn.APICertAuth, conn.kubeconfig, conn.kubecontext)
+	fmt.Fprintf(b, "token=%x token_file=%q exec=%+v\n", sha256.Sum256([]byte(conn.token)), conn.tokenFile, conn.exec)
+	fmt.Fprintf(b, "manifests=%q,%v\n", conn.manifests, conn.followManifests)
+	fmt.Fprintf(b, "impersonate=%q,%q proxy=%q tls_servername=%q insecure_skip_verify=%v\n", conn.impersonate.UserName,
+		conn.impersonate.Groups, urlString(conn.proxy), conn.tlsServerName, conn.insecure)
+	fmt.Fprintf(b, "qps=%v burst=%d user_agent=%q timeout=%v content_type=%q\n", conn.qps, conn.burst,
+		conn.userAgent, conn.timeout, conn.contentType)
+	fmt.Fprintf(b, "snapshot=%q,%v max_staleness=%v\n", k.snapshotDir, k.snapshotInterval, k.maxStaleness)
+	fmt.Fprintf(b, "namespaces=%q labels=%q namespace_labels=%q fields=%q\n", opts.Namespaces,
+		selectorString(opts.LabelSelector), selectorString(opts.NamespaceLabelSelector), selectorString(opts.FieldSelector))
+
+	names := make([]string, 0, len(regs))
+	for n := range regs {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		r := regs[n]
+		if !r.declared {
+			return ""
+		}
+		indexers := make([]string, 0, len(r.indexers))
+		for idx := range r.indexers {
+			indexers = append(indexers, idx)
+		}
+		sort.Strings(indexers)
+		fmt.Fprintf(b, "informer=%q owner=%q type=%v options=%q indexers=%q subscribers=%q\n", n, r.owner, r.objType,
+			r.options, indexers, r.subscribers)
+	}
+	return b.String()
+}
+
+// urlString returns the string form of u, or an empty string if it is nil.
+func urlString(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// selectorString returns the string form of a label or field selector, or an empty string if it is nil.
+func selectorString(s fmt.Stringer) string {
+	if s == nil || reflect.ValueOf(s).IsNil() {
+		return ""
+	}
+	return s.String()
+}
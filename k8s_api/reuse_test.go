@@ -0,0 +1,141 @@
+package k8sapi
+
+import (
+	"reflect"
+	"testing"
+
+	api "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestControlKey(t *testing.T) {
+	k := New(nil)
+	regs := map[string]*registration{
+		"pod": {owner: "first", objType: reflect.TypeOf(&api.Pod{}), declared: true, options: "a", indexers: cache.Indexers{}},
+	}
+	key := k.controlKey("", &k.Connection, regs)
+	if key == "" {
+		t.Fatal("Expected key for declared informers")
+	}
+	if k.controlKey("", &k.Connection, regs) != key {
+		t.Error("Expected same key for same configuration")
+	}
+
+	regs["pod"].options = "b"
+	if k.controlKey("", &k.Connection, regs) == key {
+		t.Error("Expected key to change with informer options")
+	}
+	regs["pod"].options = "a"
+
+	k.selector = labels.SelectorFromSet(labels.Set{"app": "dns"})
+	if k.controlKey("", &k.Connection, regs) == key {
+		t.Error("Expected key to change with k8s_api options")
+	}
+	k.selector = nil
+
+	regs["pod"].declared = false
+	if k.controlKey("", &k.Connection, regs) != "" {
+		t.Error("Expected no key for undeclared informers")
+	}
+}
+
+type countingHandler struct{ adds *int }
+
+func (h countingHandler) OnAdd(interface{})                 { *h.adds++ }
+func (h countingHandler) OnUpdate(interface{}, interface{}) {}
+func (h countingHandler) OnDelete(interface{})              {}
+
+func TestControlRegistry(t *testing.T) {
+	r := &controlRegistry{controls: make(map[*apiControl]struct{})}
+	var oldAdds, newAdds int
+	router := &eventRouter{handler: countingHandler{&oldAdds}}
+	dns := &apiControl{key: "key", routers: map[string]*eventRouter{"pod": router}}
+	if _, err := dns.start(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	prev, next := New(nil), New(nil)
+	r.add(prev, dns)
+
+	if r.adopt("key", next) != nil {
+		t.Fatal("Expected apiControl not to be adopted before its owner restarts")
+	}
+
+	// A failed restart routes events back to the prev owner.
+	r.restart(prev)
+	if r.adopt("other", next) != nil {
+		t.Fatal("Expected apiControl with another key not to be adopted")
+	}
+	if r.adopt("key", next) != dns {
+		t.Fatal("Expected apiControl to be adopted")
+	}
+	r.route(dns, "pod", countingHandler{&newAdds}, nil)
+	router.OnAdd(nil)
+	r.restartFailed(prev)
+	router.OnAdd(nil)
+	if oldAdds != 1 || newAdds != 1 {
+		t.Errorf("Expected one event for each owner, got %d and %d", oldAdds, newAdds)
+	}
+
+	// A successful restart only stops the apiControl when the next owner shuts down.
+	r.restart(prev)
+	if r.adopt("key", next) != dns {
+		t.Fatal("Expected apiControl to be adopted")
+	}
+	r.route(dns, "pod", countingHandler{&newAdds}, nil)
+	if err := r.shutdown(prev); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !dns.running {
+		t.Fatal("Expected adopted apiControl to keep running")
+	}
+	router.OnAdd(nil)
+	if oldAdds != 1 || newAdds != 2 {
+		t.Errorf("Expected events to be routed to the next owner, got %d and %d", oldAdds, newAdds)
+	}
+	if err := r.shutdown(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if dns.running {
+		t.Error("Expected apiControl to be stopped")
+	}
+}
+
+func TestControlRegistryStartupFailed(t *testing.T) {
+	r := &controlRegistry{controls: make(map[*apiControl]struct{})}
+	prev, next := New(nil), New(nil)
+	adopted := &apiControl{key: "key", routers: map[string]*eventRouter{}}
+	owned := &apiControl{key: "other", routers: map[string]*eventRouter{}}
+	for _, dns := range []*apiControl{adopted, owned} {
+		if _, err := dns.start(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	r.add(prev, adopted)
+	r.restart(prev)
+	if r.adopt("key", next) != adopted {
+		t.Fatal("Expected apiControl to be adopted")
+	}
+	r.add(next, owned)
+
+	// the next owner failed to start, and is shut down before the restart of prev fails
+	if err := r.shutdown(next); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if owned.running {
+		t.Error("Expected apiControl of the failed instance to be stopped")
+	}
+	if !adopted.running {
+		t.Fatal("Expected adopted apiControl to keep running")
+	}
+	r.restartFailed(prev)
+	if adopted.reuse.owner != prev {
+		t.Error("Expected adopted apiControl to go back to its previous owner")
+	}
+	if err := r.shutdown(prev); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if adopted.running {
+		t.Error("Expected apiControl to be stopped")
+	}
+}
@@ -0,0 +1,59 @@
+package k8sapi
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// selectorListWatch adds label and field selectors to the options of every list and watch. The selectors are
+// combined with any selectors already present in the options.
+type selectorListWatch struct {
+	lw     cache.ListerWatcher
+	labels string
+	fields string
+}
+
+func newSelectorListWatch(lw cache.ListerWatcher, ls labels.Selector, fs fields.Selector) cache.ListerWatcher {
+	slw := &selectorListWatch{lw: lw}
+	if ls != nil && !ls.Empty() {
+		slw.labels = ls.String()
+	}
+	if fs != nil && !fs.Empty() {
+		slw.fields = fs.String()
+	}
+	if slw.labels == "" && slw.fields == "" {
+		return lw
+	}
+	return slw
+}
+
+// List implements cache.Lister.
+func (s *selectorListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	return s.lw.List(s.options(options))
+}
+
+// Watch implements cache.Watcher.
+func (s *selectorListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	return s.lw.Watch(s.options(options))
+}
+
+func (s *selectorListWatch) options(options metav1.ListOptions) metav1.ListOptions {
+	options.LabelSelector = andSelector(options.LabelSelector, s.labels)
+	options.FieldSelector = andSelector(options.FieldSelector, s.fields)
+	return options
+}
+
+// andSelector returns the conjunction of two label or field selector strings.
+func andSelector(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "," + b
+}
@@ -3,21 +3,34 @@ package k8sapi
 import (
 	"context"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/caddyserver/caddy"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog"
 )
 
 const pluginName = "k8s_api"
 
+// execAPIVersion is the API version of the ExecCredential exchanged with the command of the exec option.
+const execAPIVersion = "client.authentication.k8s.io/v1beta1"
+
 var log = clog.NewWithPlugin(pluginName)
 
 func init() { plugin.Register(pluginName, setup) }
@@ -32,72 +45,266 @@ func setup(c *caddy.Controller) error {
 
 	k.RegisterKubeCache(c)
 
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		k.Next = next
+		return k
+	})
+
 	return nil
 }
 
 func (k *KubeAPI) getAPIWatchers(c *caddy.Controller) error {
-
-	config, err := k.getClientConfig()
+	// Get Informer functions from all plugins implementing Watcher
+	plugins := orderedHandlers(dnsserver.GetConfig(c).Handlers())
+	regs, err := registerInformers(plugins)
 	if err != nil {
-		return err
+		return plugin.Error(pluginName, err)
 	}
-
-	kubeClient, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return plugin.Error(pluginName, fmt.Errorf("failed to create kubernetes notification controller: %q", err))
+	// Group Informers by cluster
+	clusters := make(map[string]map[string]*registration)
+	for n, r := range regs {
+		cluster, _ := splitInformerName(n)
+		if _, ok := k.connection(cluster); !ok {
+			return plugin.Error(pluginName, fmt.Errorf("plugin %q registers informer %q for unknown cluster %q", r.owner, n, cluster))
+		}
+		if clusters[cluster] == nil {
+			clusters[cluster] = make(map[string]*registration)
+		}
+		clusters[cluster][n] = r
 	}
-
-	// Get Informer functions from all plugins implementing Watcher
-	informerFuncs := make(map[string]InformerFunc)
-	plugins := dnsserver.GetConfig(c).Handlers()
+	// Build, or reuse, the api controller of each cluster
+	controls := make(clusterControl)
+	var created []*apiControl
+	for cluster, regs := range clusters {
+		conn, _ := k.connection(cluster)
+		key := k.controlKey(cluster, conn, regs)
+		if apicon := reusable.adopt(key, k); apicon != nil {
+			log.Infof("Reusing running informers of cluster %q", cluster)
+			if err := k.handOver(apicon, regs); err != nil {
+				return err
+			}
+			controls[cluster] = apicon
+			continue
+		}
+		apicon, err := k.buildAPIControl(cluster, conn, regs)
+		if err != nil {
+			return err
+		}
+		apicon.key = key
+		controls[cluster] = apicon
+		created = append(created, apicon)
+	}
+	// Call SetIndexer for each Informer and HasSynced in all plugins implementing Watcher
 	for _, pl := range plugins {
-		if w, ok := pl.(APIWatcher); ok {
-			ifn := w.Informers()
-			for n, f := range ifn {
-				// skip if an informer with same name already exists
-				if _, ok := informerFuncs[n]; ok {
-					continue
-				}
-				informerFuncs[n] = f
+		w, ok := pl.(APIWatcher)
+		if !ok {
+			continue
+		}
+		for _, apicon := range controls {
+			if err := setIndexers(w, apicon); err != nil {
+				return err
 			}
 		}
+		names := usedInformers(pl)
+		w.SetHasSynced(func() bool {
+			// return false if at least one controller is not yet synced
+			return controls.informersSynced(names)
+		})
+		if s, ok := pl.(InformerSyncer); ok {
+			s.SetInformerSynced(controls.InformerSynced)
+		}
+		if h, ok := pl.(HealthReceiver); ok {
+			h.SetHealth(controls.Health)
+		}
 	}
-	// Call Informer functions and save result to the api controller
-	apicon := apiControl{
-		client:    kubeClient,
-		stopCh:    make(chan struct{}),
-		Informers: make(map[string]*Informer, len(informerFuncs)),
+
+	for cluster, apicon := range controls {
+		conn, _ := k.connection(cluster)
+		apicon.setRebuild(k.rebuilder(apicon, conn, clusters[cluster], plugins))
 	}
-	for n, f := range informerFuncs {
-		inf := f(context.Background(), kubeClient)
-		apicon.Informers[n] = inf
+
+	reusable.add(k, created...)
+	k.APIConn = controls
+	k.registrations = regs
+	return nil
+}
+
+// buildAPIControl calls the Informer functions of a cluster and saves the result to a new api controller.
+func (k *KubeAPI) buildAPIControl(cluster string, conn *Connection, regs map[string]*registration) (*apiControl, error) {
+	apicon, err := conn.newAPIControl(cluster)
+	if err != nil {
+		return nil, err
 	}
-	// Call SetIndexer for each Informer and HasSynced in all plugins implementing Watcher
-	for _, pl := range plugins {
-		if w, ok := pl.(APIWatcher); ok {
-			for n, i := range apicon.Informers {
-				err := w.SetIndexer(n, i.Lister)
-				if err != nil {
+	apicon.maxStaleness = k.maxStaleness
+	if err := k.buildInformers(apicon, conn, regs); err != nil {
+		return nil, err
+	}
+	return apicon, nil
+}
+
+// buildInformers calls the Informer functions of a cluster, and saves the result to the api controller, replacing
+// its Informers.
+func (k *KubeAPI) buildInformers(apicon *apiControl, conn *Connection, regs map[string]*registration) error {
+	opts := k.informerOptions()
+	opts.DynamicClient = apicon.dynamicClient
+	opts.build = newBuildValues()
+	var snapshots *snapshotSet
+	if k.snapshotDir != "" && conn.manifests == "" {
+		snapshots = newSnapshotSet(k.snapshotDir, k.snapshotInterval)
+		opts.snapshots = snapshots
+	}
+	control := informers.controlID(apicon)
+	ctx, cancel := context.WithCancel(context.Background())
+	infs := make(map[string]*Informer)
+	routers := make(map[string]*eventRouter)
+	health := make(map[string]*informerHealth)
+	objTypes := make(map[string]reflect.Type)
+	for n, r := range regs {
+		_, name := splitInformerName(n)
+		o := opts
+		o.informer = n
+		o.metrics = &informerMetrics{informer: n, control: control}
+		o.events = &eventRouter{fanout: r.eventFanout(), metrics: o.metrics}
+		o.health = newInformerHealth()
+		if r.declared {
+			// only declared Informers have options identifying how their objects are selected
+			o.snapshotKey = fmt.Sprintf("endpoint=%q kubeconfig=%q,%q owner=%q type=%v options=%q", conn.APIServer,
+				conn.kubeconfig, conn.kubecontext, r.owner, r.objType, r.options)
+		}
+		inf := r.fn(informerContext(ctx, apicon.cluster, name), apicon.client, o)
+		if err := r.addIndexers(n, inf); err != nil {
+			cancel()
+			return plugin.Error(pluginName, err)
+		}
+		if err := r.checkSubscriptions(n, o.events); err != nil {
+			cancel()
+			return plugin.Error(pluginName, err)
+		}
+		infs[name] = inf
+		routers[name] = o.events
+		health[name] = o.health
+		if r.objType != nil {
+			objTypes[name] = r.objType
+		}
+	}
+	apicon.lock.Lock()
+	defer apicon.lock.Unlock()
+	apicon.Informers, apicon.objTypes, apicon.routers, apicon.health = infs, objTypes, routers, health
+	apicon.snapshots = snapshots
+	apicon.ctx, apicon.cancel, apicon.build = ctx, cancel, opts.build
+	return nil
+}
+
+// rebuilder returns the function building the Informers of a stopped api controller again, and handing their
+// stores to the plugins.
+func (k *KubeAPI) rebuilder(apicon *apiControl, conn *Connection, regs map[string]*registration, plugins []plugin.Handler) func() error {
+	return func() error {
+		if err := k.buildInformers(apicon, conn, regs); err != nil {
+			return err
+		}
+		for _, pl := range plugins {
+			if w, ok := pl.(APIWatcher); ok {
+				if err := setIndexers(w, apicon); err != nil {
 					return err
 				}
 			}
-			w.SetHasSynced(func() bool {
-				// return false if at least one controller is not yet synced
-				for i := range w.Informers() {
-					if !apicon.Informers[i].Controller.HasSynced() {
-						return false
-					}
-				}
-				return true
-			})
+		}
+		return nil
+	}
+}
 
+// setIndexers calls SetIndexer of the plugin for each Informer of the api controller. The stores of Informers with a
+// declared object type are passed as a TypedLister.
+func setIndexers(w APIWatcher, apicon *apiControl) error {
+	apicon.lock.RLock()
+	defer apicon.lock.RUnlock()
+	for n, i := range apicon.Informers {
+		lister := i.Lister
+		if idx, ok := lister.(cache.Indexer); ok && apicon.objTypes[n] != nil {
+			lister = &typedIndexer{Indexer: idx, objType: apicon.objTypes[n]}
+		}
+		if err := w.SetIndexer(InformerName(apicon.cluster, n), lister); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	k.APIConn = &apicon
+// handOver routes the events of the Informers of a running api controller to the plugins of k. The Informer
+// functions are called to get the event handlers of the plugins, but the Informers they return are not used.
+func (k *KubeAPI) handOver(apicon *apiControl, regs map[string]*registration) error {
+	opts := k.informerOptions()
+	opts.DynamicClient = apicon.dynamicClient
+	apicon.lock.RLock()
+	ctx := apicon.ctx
+	opts.build = apicon.build
+	apicon.lock.RUnlock()
+	for n, r := range regs {
+		_, name := splitInformerName(n)
+		o := opts
+		o.informer = n
+		o.events = &eventRouter{}
+		r.fn(informerContext(ctx, apicon.cluster, name), apicon.client, o)
+		if err := r.checkSubscriptions(n, o.events); err != nil {
+			return plugin.Error(pluginName, err)
+		}
+		reusable.route(apicon, name, o.events.handler, r.eventFanout())
+	}
 	return nil
 }
 
+// newAPIControl returns an apiControl for the named cluster, with clients for the connection.
+func (k *Connection) newAPIControl(cluster string) (*apiControl, error) {
+	if k.manifests != "" {
+		source, err := newManifestSource(k.manifests, k.followManifests)
+		if err != nil {
+			return nil, plugin.Error(pluginName, fmt.Errorf("failed to load manifests: %q", err))
+		}
+		dns, err := newClientsAPIControl(cluster, source.clientConfig(k))
+		if err != nil {
+			return nil, err
+		}
+		dns.source = source
+		return dns, nil
+	}
+
+	config, err := k.getClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := newReloadingTransport(k, config)
+	if err != nil {
+		return nil, err
+	}
+	dns, err := newClientsAPIControl(cluster, transport.clientConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	dns.transport = transport
+	return dns, nil
+}
+
+// newClientsAPIControl returns an apiControl for the cluster, with clients using config.
+func newClientsAPIControl(cluster string, config *rest.Config) (*apiControl, error) {
+	kubeClient, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, plugin.Error(pluginName, fmt.Errorf("failed to create kubernetes notification controller: %q", err))
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, plugin.Error(pluginName, fmt.Errorf("failed to create kubernetes dynamic client: %q", err))
+	}
+	return &apiControl{
+		cluster:       cluster,
+		client:        kubeClient,
+		dynamicClient: dynamicClient,
+		Informers:     make(map[string]*Informer),
+		routers:       make(map[string]*eventRouter),
+		health:        make(map[string]*informerHealth),
+	}, nil
+}
+
 // RegisterKubeCache registers KubeCache start and stop functions with Caddy
 func (k *KubeAPI) RegisterKubeCache(c *caddy.Controller) {
 	c.OnStartup(func() error {
@@ -107,27 +314,76 @@ func (k *KubeAPI) RegisterKubeCache(c *caddy.Controller) {
 			return err
 		}
 
+		if k.debug != nil {
+			// serve the Informers while they sync, as that is when they are most often looked at
+			if err := k.debug.start(); err != nil {
+				reusable.shutdown(k)
+				return plugin.Error(pluginName, err)
+			}
+		}
+
 		go k.APIConn.Run()
 
-		timeout := time.After(5 * time.Second)
-		ticker := time.NewTicker(100 * time.Millisecond)
-		for {
-			select {
-			case <-ticker.C:
-				if k.APIConn.HasSynced() {
-					return nil
-				}
-			case <-timeout:
-				return nil
+		if err := k.waitForSync(); err != nil {
+			if k.debug != nil {
+				k.debug.stop()
 			}
+			// Caddy does not shut down an instance that failed to start, so stop its Informers here. They stay
+			// stopped even if Run has not started them yet.
+			reusable.shutdown(k)
+			return err
+		}
+		return nil
+	})
+
+	c.OnRestart(func() error {
+		reusable.restart(k)
+		if k.debug != nil {
+			// release the debug address for the new instance
+			return k.debug.stop()
+		}
+		return nil
+	})
+
+	c.OnRestartFailed(func() error {
+		reusable.restartFailed(k)
+		if k.debug != nil {
+			return k.debug.start()
 		}
+		return nil
 	})
 
 	c.OnShutdown(func() error {
-		return k.APIConn.Stop()
+		if k.debug != nil {
+			k.debug.stop()
+		}
+		return reusable.shutdown(k)
 	})
 }
 
+// waitForSync waits until all Informers have synced, or the sync timeout expires. If the timeout expires, an
+// error is returned if syncFail is set, otherwise the Informers that have not synced are logged.
+func (k *KubeAPI) waitForSync() error {
+	timeout := time.After(k.syncTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if k.APIConn.HasSynced() {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-timeout:
+			unsynced := strings.Join(k.APIConn.Unsynced(), ", ")
+			if k.syncFail {
+				return plugin.Error(pluginName, fmt.Errorf("informers not synced after %v: %s", k.syncTimeout, unsynced))
+			}
+			log.Warningf("Informers not synced after %v, continuing startup: %s", k.syncTimeout, unsynced)
+			return nil
+		}
+	}
+}
+
 func parse(c *caddy.Controller) (*KubeAPI, error) {
 	var (
 		kapi *KubeAPI
@@ -152,44 +408,348 @@ func parse(c *caddy.Controller) (*KubeAPI, error) {
 func parseStanza(c *caddy.Controller) (*KubeAPI, error) {
 	kapi := New([]string{""})
 	for c.NextBlock() {
+		ok, err := parseConnection(c, &kapi.Connection)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
 		switch c.Val() {
-		case "endpoint":
+		case "cluster":
 			args := c.RemainingArgs()
-			if len(args) == 1 {
-				kapi.APIServer = args[0]
+			if len(args) != 1 || !c.NextArg() || c.Val() != "{" {
+				return nil, c.ArgErr()
+			}
+			name := args[0]
+			if strings.Contains(name, "/") {
+				return nil, c.Errf("cluster name cannot contain '/': %s", name)
+			}
+			if _, ok := kapi.clusters[name]; ok {
+				return nil, c.Errf("duplicate cluster: %s", name)
+			}
+			conn, err := parseCluster(c)
+			if err != nil {
+				return nil, err
+			}
+			kapi.clusters[name] = conn
+		case "namespaces":
+			args := c.RemainingArgs()
+			if len(args) > 0 {
+				for _, a := range args {
+					kapi.namespaces[a] = struct{}{}
+				}
 				continue
 			}
 			return nil, c.ArgErr()
-		case "tls": // cert key cacertfile
+		case "labels":
 			args := c.RemainingArgs()
-			if len(args) == 3 {
-				kapi.APIClientCert, kapi.APIClientKey, kapi.APICertAuth = args[0], args[1], args[2]
+			if len(args) > 0 {
+				labelSelectorString := strings.Join(args, " ")
+				ls, err := meta.ParseToLabelSelector(labelSelectorString)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse label selector value: '%v': %v", labelSelectorString, err)
+				}
+				selector, err := meta.LabelSelectorAsSelector(ls)
+				if err != nil {
+					return nil, fmt.Errorf("unable to create selector for labels: '%s': %q", ls, err)
+				}
+				kapi.selector = selector
 				continue
 			}
 			return nil, c.ArgErr()
-		case "kubeconfig":
+		case "namespace_labels":
 			args := c.RemainingArgs()
-			if len(args) == 2 {
-				config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-					&clientcmd.ClientConfigLoadingRules{ExplicitPath: args[0]},
-					&clientcmd.ConfigOverrides{CurrentContext: args[1]},
-				)
-				kapi.ClientConfig = config
+			if len(args) > 0 {
+				namespaceLabelSelectorString := strings.Join(args, " ")
+				nls, err := meta.ParseToLabelSelector(namespaceLabelSelectorString)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse namespace_label selector value: '%v': %v", namespaceLabelSelectorString, err)
+				}
+				selector, err := meta.LabelSelectorAsSelector(nls)
+				if err != nil {
+					return nil, fmt.Errorf("unable to create selector for labels: '%s': %q", nls, err)
+				}
+				kapi.nsSelector = selector
 				continue
 			}
 			return nil, c.ArgErr()
-		case "namespaces":
+		case "fields":
 			args := c.RemainingArgs()
 			if len(args) > 0 {
-				for _, a := range args {
-					kapi.namespaces[a] = struct{}{}
+				fieldSelectorString := strings.Join(args, "")
+				selector, err := fields.ParseSelector(fieldSelectorString)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse field selector value: '%v': %v", fieldSelectorString, err)
 				}
+				kapi.fieldSelector = selector
 				continue
 			}
 			return nil, c.ArgErr()
+		case "sync_timeout":
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			d, err := time.ParseDuration(args[0])
+			if err != nil {
+				return nil, c.Errf("unable to parse sync_timeout duration: '%v': %v", args[0], err)
+			}
+			if d <= 0 {
+				return nil, c.Errf("sync_timeout must be greater than 0: %v", d)
+			}
+			kapi.syncTimeout = d
+			if len(args) == 2 {
+				switch args[1] {
+				case "continue":
+					kapi.syncFail = false
+				case "fail":
+					kapi.syncFail = true
+				default:
+					return nil, c.Errf("wrong value for sync_timeout mode: %s, must be one of: continue, fail", args[1])
+				}
+			}
+		case "max_staleness":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			d, err := time.ParseDuration(args[0])
+			if err != nil {
+				return nil, c.Errf("unable to parse max_staleness duration: '%v': %v", args[0], err)
+			}
+			if d <= 0 {
+				return nil, c.Errf("max_staleness must be greater than 0: %v", d)
+			}
+			kapi.maxStaleness = d
+		case "snapshot":
+			args := c.RemainingArgs()
+			if len(args) == 0 || len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+				return nil, c.Errf("snapshot directory does not exist: %s", args[0])
+			}
+			kapi.snapshotDir = args[0]
+			kapi.snapshotInterval = defaultSnapshotInterval
+			if len(args) == 2 {
+				d, err := time.ParseDuration(args[1])
+				if err != nil {
+					return nil, c.Errf("unable to parse snapshot interval: '%v': %v", args[1], err)
+				}
+				if d <= 0 {
+					return nil, c.Errf("snapshot interval must be greater than 0: %v", d)
+				}
+				kapi.snapshotInterval = d
+			}
+		case "debug":
+			args := c.RemainingArgs()
+			if len(args) != 1 {
+				return nil, c.ArgErr()
+			}
+			if _, _, err := net.SplitHostPort(args[0]); err != nil {
+				return nil, c.Errf("unable to parse debug address: '%v': %v", args[0], err)
+			}
+			kapi.debug = newDebugServer(args[0], kapi)
 		default:
 			return nil, c.Errf("unknown property '%s'", c.Val())
 		}
 	}
+
+	if err := kapi.Connection.validate(); err != nil {
+		return nil, c.Err(err.Error())
+	}
+
+	if len(kapi.namespaces) != 0 && kapi.nsSelector != nil {
+		return nil, c.Errf("namespaces and namespace_labels cannot both be set")
+	}
+
 	return kapi, nil
 }
+
+// parseCluster parses the connection directives of a cluster block, up to and including its closing brace.
+func parseCluster(c *caddy.Controller) (*Connection, error) {
+	conn := &Connection{}
+	for c.Next() {
+		if c.Val() == "}" {
+			if err := conn.validate(); err != nil {
+				return nil, c.Err(err.Error())
+			}
+			return conn, nil
+		}
+		ok, err := parseConnection(c, conn)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, c.Errf("unknown cluster property '%s'", c.Val())
+		}
+	}
+	return nil, c.EOFErr()
+}
+
+// parseConnection parses the current directive into conn if it is a connection directive, and returns false otherwise.
+func parseConnection(c *caddy.Controller, conn *Connection) (bool, error) {
+	switch c.Val() {
+	case "endpoint":
+		args := c.RemainingArgs()
+		if len(args) == 1 {
+			conn.APIServer = args[0]
+			return true, nil
+		}
+		return true, c.ArgErr()
+	case "tls": // cert key cacertfile
+		args := c.RemainingArgs()
+		if len(args) == 3 {
+			conn.APIClientCert, conn.APIClientKey, conn.APICertAuth = args[0], args[1], args[2]
+			return true, nil
+		}
+		return true, c.ArgErr()
+	case "kubeconfig":
+		args := c.RemainingArgs()
+		if len(args) == 2 {
+			conn.ClientConfig = newKubeconfigClientConfig(args[0], args[1])
+			conn.kubeconfig, conn.kubecontext = args[0], args[1]
+			return true, nil
+		}
+		return true, c.ArgErr()
+	case "token":
+		args := c.RemainingArgs()
+		if len(args) == 1 {
+			conn.token = args[0]
+			return true, nil
+		}
+		return true, c.ArgErr()
+	case "token_file":
+		args := c.RemainingArgs()
+		if len(args) == 1 {
+			conn.tokenFile = args[0]
+			return true, nil
+		}
+		return true, c.ArgErr()
+	case "exec":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return true, c.ArgErr()
+		}
+		conn.exec = &clientcmdapi.ExecConfig{
+			APIVersion: execAPIVersion,
+			Command:    args[0],
+			Args:       args[1:],
+		}
+		return true, nil
+	case "qps":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		qps, err := strconv.ParseFloat(args[0], 32)
+		if err != nil || qps <= 0 {
+			return true, c.Errf("qps must be a number greater than 0: %s", args[0])
+		}
+		conn.qps = float32(qps)
+		return true, nil
+	case "burst":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		burst, err := strconv.Atoi(args[0])
+		if err != nil || burst <= 0 {
+			return true, c.Errf("burst must be an integer greater than 0: %s", args[0])
+		}
+		conn.burst = burst
+		return true, nil
+	case "user_agent":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return true, c.ArgErr()
+		}
+		conn.userAgent = strings.Join(args, " ")
+		return true, nil
+	case "timeout":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		d, err := time.ParseDuration(args[0])
+		if err != nil {
+			return true, c.Errf("unable to parse timeout duration: '%v': %v", args[0], err)
+		}
+		if d <= 0 {
+			return true, c.Errf("timeout must be greater than 0: %v", d)
+		}
+		conn.timeout = d
+		return true, nil
+	case "manifests":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return true, c.ArgErr()
+		}
+		if len(args) == 2 {
+			if args[1] != "follow" {
+				return true, c.Errf("unknown manifests option: %s", args[1])
+			}
+			conn.followManifests = true
+		}
+		if _, err := os.Stat(args[0]); err != nil {
+			return true, c.Errf("unable to read manifests: %v", err)
+		}
+		conn.manifests = args[0]
+		return true, nil
+	case "impersonate":
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return true, c.ArgErr()
+		}
+		conn.impersonate = rest.ImpersonationConfig{UserName: args[0], Groups: args[1:]}
+		return true, nil
+	case "proxy":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		u, err := url.Parse(args[0])
+		if err != nil {
+			return true, c.Errf("unable to parse proxy URL: '%v': %v", args[0], err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return true, c.Errf("wrong scheme for proxy URL: '%v', must be one of: http, https, socks5", args[0])
+		}
+		if u.Host == "" {
+			return true, c.Errf("proxy URL has no host: '%v'", args[0])
+		}
+		conn.proxy = u
+		return true, nil
+	case "tls_servername":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		conn.tlsServerName = args[0]
+		return true, nil
+	case "insecure_skip_verify":
+		if len(c.RemainingArgs()) != 0 {
+			return true, c.ArgErr()
+		}
+		conn.insecure = true
+		return true, nil
+	case "content_type":
+		args := c.RemainingArgs()
+		if len(args) != 1 {
+			return true, c.ArgErr()
+		}
+		switch args[0] {
+		case "json":
+			conn.contentType = contentTypeJSON
+		case "protobuf":
+			conn.contentType = contentTypeProtobuf
+		default:
+			return true, c.Errf("wrong value for content_type: %s, must be one of: json, protobuf", args[0])
+		}
+		return true, nil
+	}
+	return false, nil
+}
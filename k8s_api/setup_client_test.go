@@ -0,0 +1,244 @@
+package k8sapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestParseClientSettings(t *testing.T) {
+	tests := []struct {
+		input               string // Corefile data as string
+		shouldErr           bool
+		expectedQPS         float32
+		expectedBurst       int
+		expectedUserAgent   string
+		expectedTimeout     time.Duration
+		expectedContentType string
+	}{
+		{`k8s_api {
+			endpoint http://localhost:8080
+		}`, false, 0, 0, "", 0, contentTypeProtobuf},
+		{`k8s_api {
+			endpoint http://localhost:8080
+			qps 50.5
+			burst 100
+			user_agent coredns k8s_api
+			timeout 30s
+			content_type json
+		}`, false, 50.5, 100, "coredns k8s_api", 30 * time.Second, contentTypeJSON},
+		{`k8s_api {
+			qps 0
+		}`, true, 0, 0, "", 0, ""},
+		{`k8s_api {
+			burst 1.5
+		}`, true, 0, 0, "", 0, ""},
+		{`k8s_api {
+			user_agent
+		}`, true, 0, 0, "", 0, ""},
+		{`k8s_api {
+			timeout 10
+		}`, true, 0, 0, "", 0, ""},
+		{`k8s_api {
+			content_type yaml
+		}`, true, 0, 0, "", 0, ""},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+
+		cc, err := k.getClientConfig()
+		if err != nil {
+			t.Fatalf("Test %d: Expected no error getting client config, got %q", i, err)
+		}
+		if cc.QPS != tc.expectedQPS {
+			t.Errorf("Test %d: Expected qps %v, got %v", i, tc.expectedQPS, cc.QPS)
+		}
+		if cc.Burst != tc.expectedBurst {
+			t.Errorf("Test %d: Expected burst %v, got %v", i, tc.expectedBurst, cc.Burst)
+		}
+		if cc.UserAgent != tc.expectedUserAgent {
+			t.Errorf("Test %d: Expected user agent %q, got %q", i, tc.expectedUserAgent, cc.UserAgent)
+		}
+		if cc.Timeout != tc.expectedTimeout {
+			t.Errorf("Test %d: Expected timeout %v, got %v", i, tc.expectedTimeout, cc.Timeout)
+		}
+		if cc.ContentType != tc.expectedContentType {
+			t.Errorf("Test %d: Expected content type %q, got %q", i, tc.expectedContentType, cc.ContentType)
+		}
+	}
+}
+
+func TestParseAuthSettings(t *testing.T) {
+	tests := []struct {
+		input             string // Corefile data as string
+		shouldErr         bool
+		expectedToken     string
+		expectedTokenFile string
+		expectedExec      []string
+	}{
+		{`k8s_api {
+			endpoint https://localhost:8443
+			token abc
+		}`, false, "abc", "", nil},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			token_file /var/run/token
+		}`, false, "", "/var/run/token", nil},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			exec aws eks get-token
+		}`, false, "", "", []string{"aws", "eks", "get-token"}},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			token abc
+			token_file /var/run/token
+		}`, true, "", "", nil},
+		{`k8s_api {
+			token abc
+		}`, true, "", "", nil},
+		{`k8s_api {
+			kubeconfig /tmp/kubeconfig
+			exec get-token
+		}`, true, "", "", nil},
+		{`k8s_api {
+			cluster remote {
+				exec get-token
+			}
+		}`, true, "", "", nil},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			token
+		}`, true, "", "", nil},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			exec
+		}`, true, "", "", nil},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+
+		if k.token != tc.expectedToken {
+			t.Errorf("Test %d: Expected token %q, got %q", i, tc.expectedToken, k.token)
+		}
+		if k.tokenFile != tc.expectedTokenFile {
+			t.Errorf("Test %d: Expected token file %q, got %q", i, tc.expectedTokenFile, k.tokenFile)
+		}
+		var exec []string
+		if k.exec != nil {
+			exec = append([]string{k.exec.Command}, k.exec.Args...)
+		}
+		if !reflect.DeepEqual(exec, tc.expectedExec) {
+			t.Errorf("Test %d: Expected exec %q, got %q", i, tc.expectedExec, exec)
+		}
+	}
+}
+
+func TestParseTransportSettings(t *testing.T) {
+	tests := []struct {
+		input              string // Corefile data as string
+		shouldErr          bool
+		expectedUser       string
+		expectedGroups     []string
+		expectedProxy      string
+		expectedServerName string
+		expectedInsecure   bool
+	}{
+		{`k8s_api {
+			endpoint https://localhost:8443
+		}`, false, "", nil, "", "", false},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			impersonate coredns system:authenticated dns
+			proxy http://proxy.example.com:3128
+			tls_servername kubernetes.default.svc
+			insecure_skip_verify
+		}`, false, "coredns", []string{"system:authenticated", "dns"}, "http://proxy.example.com:3128",
+			"kubernetes.default.svc", true},
+		{`k8s_api {
+			impersonate
+		}`, true, "", nil, "", "", false},
+		{`k8s_api {
+			proxy ftp://proxy.example.com
+		}`, true, "", nil, "", "", false},
+		{`k8s_api {
+			proxy proxy.example.com:3128
+		}`, true, "", nil, "", "", false},
+		{`k8s_api {
+			tls_servername
+		}`, true, "", nil, "", "", false},
+		{`k8s_api {
+			insecure_skip_verify yes
+		}`, true, "", nil, "", "", false},
+		{`k8s_api {
+			endpoint https://localhost:8443
+			tls cert key cacert
+			insecure_skip_verify
+		}`, true, "", nil, "", "", false},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+
+		cc, err := k.getClientConfig()
+		if err != nil {
+			t.Fatalf("Test %d: Expected no error getting client config, got %q", i, err)
+		}
+		if cc.Impersonate.UserName != tc.expectedUser {
+			t.Errorf("Test %d: Expected impersonated user %q, got %q", i, tc.expectedUser, cc.Impersonate.UserName)
+		}
+		if !reflect.DeepEqual(cc.Impersonate.Groups, tc.expectedGroups) {
+			t.Errorf("Test %d: Expected impersonated groups %q, got %q", i, tc.expectedGroups, cc.Impersonate.Groups)
+		}
+		proxy := ""
+		if cc.Proxy != nil {
+			u, _ := cc.Proxy(nil)
+			proxy = u.String()
+		}
+		if proxy != tc.expectedProxy {
+			t.Errorf("Test %d: Expected proxy %q, got %q", i, tc.expectedProxy, proxy)
+		}
+		if cc.ServerName != tc.expectedServerName {
+			t.Errorf("Test %d: Expected TLS server name %q, got %q", i, tc.expectedServerName, cc.ServerName)
+		}
+		if cc.Insecure != tc.expectedInsecure {
+			t.Errorf("Test %d: Expected insecure %v, got %v", i, tc.expectedInsecure, cc.Insecure)
+		}
+	}
+}
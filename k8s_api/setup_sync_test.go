@@ -0,0 +1,87 @@
+package k8sapi
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestParseSyncTimeout(t *testing.T) {
+	tests := []struct {
+		input           string        // Corefile data as string
+		expectedTimeout time.Duration // expected sync timeout
+		expectedFail    bool          // expected sync timeout mode
+		shouldErr       bool
+	}{
+		{`k8s_api`, defaultSyncTimeout, false, false},
+		{`k8s_api {
+			sync_timeout 30s
+		}`, 30 * time.Second, false, false},
+		{`k8s_api {
+			sync_timeout 1m fail
+		}`, time.Minute, true, false},
+		{`k8s_api {
+			sync_timeout 1m continue
+		}`, time.Minute, false, false},
+		{`k8s_api {
+			sync_timeout
+		}`, 0, false, true},
+		{`k8s_api {
+			sync_timeout 0s
+		}`, 0, false, true},
+		{`k8s_api {
+			sync_timeout 10
+		}`, 0, false, true},
+		{`k8s_api {
+			sync_timeout 10s wait
+		}`, 0, false, true},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+
+		if k.syncTimeout != tc.expectedTimeout {
+			t.Errorf("Test %d: Expected sync timeout to be %v, got %v", i, tc.expectedTimeout, k.syncTimeout)
+		}
+		if k.syncFail != tc.expectedFail {
+			t.Errorf("Test %d: Expected sync fail to be %v, got %v", i, tc.expectedFail, k.syncFail)
+		}
+	}
+}
+
+type unsyncedControl struct{ apiControl }
+
+func (*unsyncedControl) HasSynced() bool    { return false }
+func (*unsyncedControl) Unsynced() []string { return []string{"endpoints", "pod"} }
+
+func TestWaitForSync(t *testing.T) {
+	k := New(nil)
+	k.syncTimeout = 10 * time.Millisecond
+	k.APIConn = &unsyncedControl{}
+
+	if err := k.waitForSync(); err != nil {
+		t.Errorf("Expected no error in continue mode, got %q", err)
+	}
+
+	k.syncFail = true
+	err := k.waitForSync()
+	if err == nil {
+		t.Fatal("Expected error in fail mode, got none")
+	}
+	if !strings.Contains(err.Error(), "endpoints, pod") {
+		t.Errorf("Expected error to list unsynced informers, got %q", err)
+	}
+}
@@ -0,0 +1,178 @@
+package k8sapi
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input              string   // Corefile data as string
+		shouldErr          bool     // true if test case is expected to produce an error.
+		expectedErrContent string   // substring from the expected error. Empty for positive cases.
+		expectedNamespaces []string // expected namespaces passed to informers.
+		expectedLabels     string   // expected label selector value
+		expectedNsLabels   string   // expected namespace label selector value
+		expectedFields     string   // expected field selector value
+	}{
+		// positive
+		{
+			`k8s_api`,
+			false,
+			"",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	namespaces demo
+}`,
+			false,
+			"",
+			[]string{"demo"},
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	namespaces test demo
+}`,
+			false,
+			"",
+			[]string{"demo", "test"},
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	labels environment in (production, staging, qa),application=nginx
+}`,
+			false,
+			"",
+			nil,
+			"application=nginx,environment in (production,qa,staging)",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	namespace_labels istio-injection=enabled
+	fields metadata.name!=kubernetes, metadata.namespace!=kube-system
+}`,
+			false,
+			"",
+			nil,
+			"",
+			"istio-injection=enabled",
+			"metadata.name!=kubernetes,metadata.namespace!=kube-system",
+		},
+		// negative
+		{
+			`k8s_api {
+	namespaces
+}`,
+			true,
+			"rong argument count or unexpected line ending",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	labels environment in (production, qa
+}`,
+			true,
+			"unable to parse label selector",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	fields metadata.name
+}`,
+			true,
+			"unable to parse field selector",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	namespaces foo bar
+	namespace_labels istio-injection=enabled
+}`,
+			true,
+			"namespaces and namespace_labels cannot both be set",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api {
+	foo
+}`,
+			true,
+			"unknown property 'foo'",
+			nil,
+			"",
+			"",
+			"",
+		},
+		{
+			`k8s_api
+k8s_api`,
+			true,
+			"this plugin",
+			nil,
+			"",
+			"",
+			"",
+		},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k, err := parse(c)
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: Expected error, but did not find error for input '%s'. Error was: '%v'", i, test.input, err)
+				continue
+			}
+			if !strings.Contains(err.Error(), test.expectedErrContent) {
+				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: Expected no error, but got error: %v, input: '%s'", i, err, test.input)
+			continue
+		}
+
+		opts := k.informerOptions()
+		if !reflect.DeepEqual(opts.Namespaces, test.expectedNamespaces) {
+			t.Errorf("Test %d: Expected namespaces %v, got %v", i, test.expectedNamespaces, opts.Namespaces)
+		}
+		if got := selectorString(opts.LabelSelector); got != test.expectedLabels {
+			t.Errorf("Test %d: Expected label selector '%s', got '%s'", i, test.expectedLabels, got)
+		}
+		if got := selectorString(opts.NamespaceLabelSelector); got != test.expectedNsLabels {
+			t.Errorf("Test %d: Expected namespace label selector '%s', got '%s'", i, test.expectedNsLabels, got)
+		}
+		if got := selectorString(opts.FieldSelector); got != test.expectedFields {
+			t.Errorf("Test %d: Expected field selector '%s', got '%s'", i, test.expectedFields, got)
+		}
+	}
+}
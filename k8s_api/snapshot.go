@@ -0,0 +1,355 @@
+package k8sapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultSnapshotInterval is how often snapshots are written, if not set by the snapshot option.
+const defaultSnapshotInterval = time.Minute
+
+// snapshotSet writes snapshots of the objects listed and watched by the Informers of an apiControl to a directory,
+// so that after a restart, the Informers start from the snapshots instead of waiting for a full list.
+type snapshotSet struct {
+	dir      string
+	interval time.Duration
+
+	lock  sync.Mutex
+	snaps []*informerSnapshot
+}
+
+func newSnapshotSet(dir string, interval time.Duration) *snapshotSet {
+	return &snapshotSet{dir: dir, interval: interval}
+}
+
+// snapshot returns the snapshot of the objects of the Informer in the namespace. key identifies the configuration of
+// the Informer, a snapshot written with another key is not used.
+func (s *snapshotSet) snapshot(informer, namespace, key string) *informerSnapshot {
+	name := url.QueryEscape(informer)
+	if namespace != "" {
+		name += "@" + url.QueryEscape(namespace)
+	}
+	snap := &informerSnapshot{
+		file:    filepath.Join(s.dir, name+".json"),
+		key:     key,
+		objects: make(map[string]json.RawMessage),
+	}
+	s.lock.Lock()
+	s.snaps = append(s.snaps, snap)
+	s.lock.Unlock()
+	return snap
+}
+
+// run writes the snapshots periodically until stopCh is closed.
+func (s *snapshotSet) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			s.save()
+		}
+	}
+}
+
+// save writes the snapshots that changed since they were last written.
+func (s *snapshotSet) save() {
+	s.lock.Lock()
+	snaps := append([]*informerSnapshot(nil), s.snaps...)
+	s.lock.Unlock()
+	for _, snap := range snaps {
+		if err := snap.save(); err != nil {
+			log.Warningf("Failed to write snapshot %s: %v", snap.file, err)
+		}
+	}
+}
+
+// snapshot is the content of a snapshot file.
+type snapshot struct {
+	Key          string          `json:"key"`
+	Kind         string          `json:"kind"`
+	APIVersion   string          `json:"apiVersion"`
+	Unstructured bool            `json:"unstructured,omitempty"`
+	List         json.RawMessage `json:"list"`
+}
+
+// informerSnapshot is a listWatchObserver keeping the objects listed and watched by an Informer in a namespace,
+// with their resource version. The objects are kept encoded, as they are written, so that the snapshot does not hold
+// a second copy of the API objects. The first list is answered from the snapshot file, if any, so that the Reflector
+// watches from the resource version of the snapshot. If the API server no longer has it, the watch fails with
+// 410 Gone, and the Reflector lists again.
+type informerSnapshot struct {
+	file string
+	key  string
+
+	lock     sync.Mutex
+	restored bool
+	listKey  string         // listKey is the key of the last list, including its selectors
+	list     runtime.Object // list is an empty list of the type listed
+	objects  map[string]json.RawMessage
+	version  string
+	changed  bool
+}
+
+var _ listWatchObserver = &informerSnapshot{}
+
+// keyFor returns the key of the snapshot of a list with options.
+func (s *informerSnapshot) keyFor(options metav1.ListOptions) string {
+	return s.key + " labels=" + options.LabelSelector + " fields=" + options.FieldSelector
+}
+
+// restore returns the list of the snapshot file, the first time it is called, if the snapshot was written with
+// the same key.
+func (s *informerSnapshot) restore(options metav1.ListOptions) runtime.Object {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.restored {
+		return nil
+	}
+	s.restored = true
+
+	data, err := ioutil.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		log.Warningf("Failed to read snapshot %s: %v", s.file, err)
+		return nil
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		log.Warningf("Failed to read snapshot %s: %v", s.file, err)
+		return nil
+	}
+	if snap.Key != s.keyFor(options) {
+		log.Infof("Ignoring snapshot %s of another configuration", s.file)
+		return nil
+	}
+	list, err := decodeSnapshotList(&snap)
+	if err != nil {
+		log.Warningf("Failed to read snapshot %s: %v", s.file, err)
+		return nil
+	}
+	if err := s.reset(list, snap.Key); err != nil {
+		log.Warningf("Failed to read snapshot %s: %v", s.file, err)
+		return nil
+	}
+	s.changed = false
+	log.Infof("Starting from snapshot %s at resource version %s", s.file, s.version)
+	return list
+}
+
+// listed implements listWatchObserver. It records a list, or a page of it.
+func (s *informerSnapshot) listed(list runtime.Object, options metav1.ListOptions, err error) {
+	if err != nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.restored = true
+	if options.Continue != "" {
+		err = s.add(list)
+	} else {
+		err = s.reset(list, s.keyFor(options))
+	}
+	if err != nil {
+		log.Warningf("Failed to snapshot list for %s: %v", s.file, err)
+	}
+}
+
+// watched implements listWatchObserver.
+func (s *informerSnapshot) watched(error) {}
+
+// reset replaces the objects with the items of list, listed with the key. s.lock must be held.
+func (s *informerSnapshot) reset(list runtime.Object, key string) error {
+	s.listKey = key
+	s.list = emptyList(list)
+	s.objects = make(map[string]json.RawMessage)
+	return s.add(list)
+}
+
+// add adds the items of list to the objects. s.lock must be held.
+func (s *informerSnapshot) add(list runtime.Object) error {
+	lm, err := meta.ListAccessor(list)
+	if err != nil {
+		return err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	for _, obj := range items {
+		key, err := cache.MetaNamespaceKeyFunc(obj)
+		if err != nil {
+			return err
+		}
+		if s.objects[key], err = json.Marshal(obj); err != nil {
+			return err
+		}
+	}
+	s.version = lm.GetResourceVersion()
+	s.changed = true
+	return nil
+}
+
+// observe implements listWatchObserver. It records the watch event e.
+func (s *informerSnapshot) observe(e watch.Event) {
+	o, err := meta.Accessor(e.Object)
+	if err != nil || e.Type == watch.Error {
+		return
+	}
+	key, _ := cache.MetaNamespaceKeyFunc(e.Object)
+	var data json.RawMessage
+	if e.Type == watch.Added || e.Type == watch.Modified {
+		if data, err = json.Marshal(e.Object); err != nil {
+			log.Warningf("Failed to snapshot %s for %s: %v", key, s.file, err)
+			return
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch e.Type {
+	case watch.Added, watch.Modified:
+		s.objects[key] = data
+	case watch.Deleted:
+		delete(s.objects, key)
+	}
+	s.version = o.GetResourceVersion()
+	s.changed = true
+}
+
+// save writes the snapshot file, if the objects changed since it was last written.
+func (s *informerSnapshot) save() error {
+	s.lock.Lock()
+	if !s.changed || s.list == nil {
+		s.lock.Unlock()
+		return nil
+	}
+	list := s.list.DeepCopyObject()
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	items := make([]json.RawMessage, 0, len(keys))
+	for _, key := range keys {
+		items = append(items, s.objects[key])
+	}
+	key, version := s.listKey, s.version
+	s.changed = false
+	s.lock.Unlock()
+
+	err := s.write(list, items, key, version)
+	if err != nil {
+		s.lock.Lock()
+		s.changed = true
+		s.lock.Unlock()
+	}
+	return err
+}
+
+func (s *informerSnapshot) write(list runtime.Object, items []json.RawMessage, key, version string) error {
+	lm, err := meta.ListAccessor(list)
+	if err != nil {
+		return err
+	}
+	lm.SetResourceVersion(version)
+	snap := snapshot{Key: key}
+	var gvk schema.GroupVersionKind
+	if u, ok := list.(*unstructured.UnstructuredList); ok {
+		snap.Unstructured = true
+		gvk = u.GroupVersionKind()
+	} else {
+		gvks, _, err := scheme.Scheme.ObjectKinds(list)
+		if err != nil {
+			return err
+		}
+		gvk = gvks[0]
+	}
+	snap.APIVersion, snap.Kind = gvk.ToAPIVersionAndKind()
+	if snap.List, err = encodeList(list, items); err != nil {
+		return err
+	}
+	data, err := json.Marshal(&snap)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so that the snapshot file is never partially written
+	f, err := ioutil.TempFile(filepath.Dir(s.file), ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.file)
+}
+
+// encodeList returns the JSON encoding of the empty list with the encoded items.
+func encodeList(list runtime.Object, items []json.RawMessage) (json.RawMessage, error) {
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if fields["items"], err = json.Marshal(items); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// emptyList returns an empty list of the type of list.
+func emptyList(list runtime.Object) runtime.Object {
+	if u, ok := list.(*unstructured.UnstructuredList); ok {
+		empty := &unstructured.UnstructuredList{}
+		empty.SetGroupVersionKind(u.GroupVersionKind())
+		return empty
+	}
+	return reflect.New(reflect.TypeOf(list).Elem()).Interface().(runtime.Object)
+}
+
+// decodeSnapshotList returns the list of the snapshot, of the type it was written from.
+func decodeSnapshotList(snap *snapshot) (runtime.Object, error) {
+	if snap.Unstructured {
+		list := &unstructured.UnstructuredList{}
+		if err := list.UnmarshalJSON(snap.List); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	list, err := scheme.Scheme.New(schema.FromAPIVersionAndKind(snap.APIVersion, snap.Kind))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(snap.List, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,219 @@
+package k8sapi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+	api "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// startSnapshotInformer starts a Service Informer using snapshots in dir, and returns its store and the number of
+// lists sent to the client.
+func startSnapshotInformer(t *testing.T, client kubernetes.Interface, dir, key string,
+	stopCh chan struct{}) (*snapshotSet, cache.Store, *int32) {
+	lists := new(int32)
+	snapshots := newSnapshotSet(dir, time.Hour)
+	opts := InformerOptions{informer: "test/service", snapshots: snapshots, snapshotKey: key}
+	lw := opts.ListerWatcher(func(ns string) cache.ListerWatcher {
+		return &cache.ListWatch{
+			ListFunc: func(o meta.ListOptions) (runtime.Object, error) {
+				atomic.AddInt32(lists, 1)
+				return client.CoreV1().Services(ns).List(context.Background(), o)
+			},
+			WatchFunc: func(o meta.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Services(ns).Watch(context.Background(), o)
+			},
+		}
+	})
+	store, controller := cache.NewInformer(lw, &api.Service{}, 0, cache.ResourceEventHandlerFuncs{})
+	go controller.Run(stopCh)
+	if !cache.WaitForCacheSync(stopCh, controller.HasSynced) {
+		t.Fatal("Expected informer to sync")
+	}
+	return snapshots, store, lists
+}
+
+func waitForKeys(t *testing.T, store cache.Store, keys ...string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got := store.ListKeys()
+		ok := len(got) == len(keys)
+		for _, key := range keys {
+			if _, exists, _ := store.GetByKey(key); !exists {
+				ok = false
+			}
+		}
+		if ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected keys %v, got %v", keys, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSnapshotListWatch(t *testing.T) {
+	manifests, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(manifests)
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeManifests(t, manifests, map[string]string{"services.yaml": testServices})
+	source, client := newTestManifestClient(t, manifests)
+
+	// the first start lists the objects, and writes them to the snapshot
+	stopCh := make(chan struct{})
+	snapshots, store, lists := startSnapshotInformer(t, client, dir, "key", stopCh)
+	waitForKeys(t, store, "testns/svc1", "other/svc2")
+	snapshots.save()
+	close(stopCh)
+	if _, err := os.Stat(filepath.Join(dir, "test%2Fservice.json")); err != nil {
+		t.Fatalf("Expected snapshot file: %v", err)
+	}
+	if atomic.LoadInt32(lists) != 1 {
+		t.Errorf("Expected 1 list, got %d", atomic.LoadInt32(lists))
+	}
+
+	// the next start resumes from the snapshot, without a list
+	stopCh = make(chan struct{})
+	snapshots, store, lists = startSnapshotInformer(t, client, dir, "key", stopCh)
+	waitForKeys(t, store, "testns/svc1", "other/svc2")
+	writeManifests(t, manifests, map[string]string{"services.yaml": strings.Replace(testServices, "svc2", "svc3", 1)})
+	source.reloadIfChanged()
+	waitForKeys(t, store, "testns/svc1", "other/svc3")
+	if atomic.LoadInt32(lists) != 0 {
+		t.Errorf("Expected no list, got %d", atomic.LoadInt32(lists))
+	}
+	snapshots.save()
+	close(stopCh)
+
+	// changes missed while stopped expire the resource version of the snapshot, and the objects are listed again
+	writeManifests(t, manifests, map[string]string{"services.yaml": strings.Replace(testServices, "svc2", "svc4", 1)})
+	source.reloadIfChanged()
+	stopCh = make(chan struct{})
+	_, store, lists = startSnapshotInformer(t, client, dir, "key", stopCh)
+	waitForKeys(t, store, "testns/svc1", "other/svc4")
+	if atomic.LoadInt32(lists) != 1 {
+		t.Errorf("Expected 1 list, got %d", atomic.LoadInt32(lists))
+	}
+	close(stopCh)
+
+	// a snapshot of another configuration is not used
+	stopCh = make(chan struct{})
+	defer close(stopCh)
+	_, store, lists = startSnapshotInformer(t, client, dir, "other", stopCh)
+	waitForKeys(t, store, "testns/svc1", "other/svc4")
+	if atomic.LoadInt32(lists) != 1 {
+		t.Errorf("Expected 1 list, got %d", atomic.LoadInt32(lists))
+	}
+}
+
+func TestSnapshotUnstructured(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion("example.com/v1")
+	list.SetKind("DNSRecordList")
+	list.SetResourceVersion("5")
+	item := unstructured.Unstructured{}
+	item.SetAPIVersion("example.com/v1")
+	item.SetKind("DNSRecord")
+	item.SetNamespace("testns")
+	item.SetName("rec1")
+	list.Items = append(list.Items, item)
+	lw := &cache.ListWatch{ListFunc: func(meta.ListOptions) (runtime.Object, error) { return list, nil }}
+
+	snapshots := newSnapshotSet(dir, time.Hour)
+	snap := snapshots.snapshot("dnsrecord", "testns", "key")
+	tlw := &trackedListWatch{lw: lw, snapshot: snap, observers: []listWatchObserver{snap}}
+	if _, err := tlw.List(meta.ListOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	snapshots.save()
+
+	snap = newSnapshotSet(dir, time.Hour).snapshot("dnsrecord", "testns", "key")
+	tlw = &trackedListWatch{snapshot: snap, observers: []listWatchObserver{snap}}
+	got, err := tlw.List(meta.ListOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	restored, ok := got.(*unstructured.UnstructuredList)
+	if !ok {
+		t.Fatalf("Expected unstructured list, got %T", got)
+	}
+	if restored.GetResourceVersion() != "5" || len(restored.Items) != 1 || restored.Items[0].GetName() != "rec1" {
+		t.Errorf("Expected rec1 at resource version 5, got %+v", restored)
+	}
+}
+
+func TestParseSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "k8s_api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		input            string // Corefile data as string
+		shouldErr        bool
+		expectedInterval time.Duration
+	}{
+		{`k8s_api {
+			snapshot ` + dir + `
+		}`, false, defaultSnapshotInterval},
+		{`k8s_api {
+			snapshot ` + dir + ` 30s
+		}`, false, 30 * time.Second},
+		{`k8s_api {
+			snapshot ` + dir + ` 0s
+		}`, true, 0},
+		{`k8s_api {
+			snapshot ` + filepath.Join(dir, "missing") + `
+		}`, true, 0},
+		{`k8s_api {
+			snapshot
+		}`, true, 0},
+	}
+
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		k, err := parse(c)
+		if err != nil && !tc.shouldErr {
+			t.Fatalf("Test %d: Expected no error, got %q", i, err)
+		}
+		if err == nil && tc.shouldErr {
+			t.Fatalf("Test %d: Expected error, got none", i)
+		}
+		if err != nil && tc.shouldErr {
+			// input should error
+			continue
+		}
+		if k.snapshotDir != dir || k.snapshotInterval != tc.expectedInterval {
+			t.Errorf("Test %d: Expected snapshot %s every %v, got %s every %v", i, dir, tc.expectedInterval,
+				k.snapshotDir, k.snapshotInterval)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package k8sapi
+
+import (
+	"sync"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// eventRouter is the ResourceEventHandler of an Informer. It passes events to the handler of the plugin that built
+// the Informer, and to the plugins subscribed to it. Both can be replaced while the Informer runs, so that a running
+// Informer can be handed over to the plugins of a new k8s_api instance after a Corefile reload.
+type eventRouter struct {
+	lock    sync.RWMutex
+	handler cache.ResourceEventHandler
+	fanout  *eventFanout
+
+	// used is set when the Informer's handler has been wrapped by InformerOptions.EventHandler.
+	used bool
+
+	// metrics counts the events of the Informer, if not nil.
+	metrics *informerMetrics
+}
+
+// EventHandler returns the ResourceEventHandler the Informer should be built with. Events are passed to h (which
+// may be nil) and then queued for the plugins subscribed to the Informer. InformerFuncs should always wrap their
+// handler with EventHandler, otherwise k8s_api fails to start if another plugin subscribes to the Informer.
+func (o InformerOptions) EventHandler(h cache.ResourceEventHandler) cache.ResourceEventHandler {
+	if o.events == nil {
+		if h == nil {
+			return cache.ResourceEventHandlerFuncs{}
+		}
+		return h
+	}
+	o.events.lock.Lock()
+	defer o.events.lock.Unlock()
+	o.events.handler = h
+	o.events.used = true
+	return o.events
+}
+
+// route replaces the handler and fanout of the router, and returns the ones replaced.
+func (r *eventRouter) route(h cache.ResourceEventHandler, f *eventFanout) (cache.ResourceEventHandler, *eventFanout) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	oldH, oldF := r.handler, r.fanout
+	r.handler, r.fanout = h, f
+	return oldH, oldF
+}
+
+func (r *eventRouter) handlers() (cache.ResourceEventHandler, *eventFanout) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.handler, r.fanout
+}
+
+// OnAdd implements cache.ResourceEventHandler.
+func (r *eventRouter) OnAdd(obj interface{}) {
+	if r.metrics != nil {
+		r.metrics.event("add")
+	}
+	h, f := r.handlers()
+	if h != nil {
+		h.OnAdd(obj)
+	}
+	if f != nil {
+		f.OnAdd(obj)
+	}
+}
+
+// OnUpdate implements cache.ResourceEventHandler.
+func (r *eventRouter) OnUpdate(oldObj, newObj interface{}) {
+	if r.metrics != nil {
+		r.metrics.event("update")
+	}
+	h, f := r.handlers()
+	if h != nil {
+		h.OnUpdate(oldObj, newObj)
+	}
+	if f != nil {
+		f.OnUpdate(oldObj, newObj)
+	}
+}
+
+// OnDelete implements cache.ResourceEventHandler.
+func (r *eventRouter) OnDelete(obj interface{}) {
+	if r.metrics != nil {
+		r.metrics.event("delete")
+	}
+	h, f := r.handlers()
+	if h != nil {
+		h.OnDelete(obj)
+	}
+	if f != nil {
+		f.OnDelete(obj)
+	}
+}
+
+// eventFanout delivers the events of an Informer to the plugins subscribed to it. Each subscriber has its own
+// queue and goroutine, so a slow subscriber neither blocks the Informer nor delays the other subscribers.
+type eventFanout struct {
+	subscribers []*subscriber
+}
+
+func newEventFanout(handlers []cache.ResourceEventHandler) *eventFanout {
+	f := &eventFanout{}
+	for _, h := range handlers {
+		f.subscribers = append(f.subscribers, newSubscriber(h))
+	}
+	return f
+}
+
+// start starts delivering queued events to subscribers.
+func (f *eventFanout) start() {
+	for _, s := range f.subscribers {
+		go s.run()
+	}
+}
+
+// stop stops delivering events to subscribers, dropping queued events.
+func (f *eventFanout) stop() {
+	for _, s := range f.subscribers {
+		s.stop()
+	}
+}
+
+// OnAdd implements cache.ResourceEventHandler.
+func (f *eventFanout) OnAdd(obj interface{}) { f.notify(event{kind: eventAdd, obj: obj}) }
+
+// OnUpdate implements cache.ResourceEventHandler.
+func (f *eventFanout) OnUpdate(oldObj, newObj interface{}) {
+	f.notify(event{kind: eventUpdate, oldObj: oldObj, obj: newObj})
+}
+
+// OnDelete implements cache.ResourceEventHandler.
+func (f *eventFanout) OnDelete(obj interface{}) { f.notify(event{kind: eventDelete, obj: obj}) }
+
+func (f *eventFanout) notify(e event) {
+	for _, s := range f.subscribers {
+		s.add(e)
+	}
+}
+
+type eventKind int
+
+const (
+	eventAdd eventKind = iota
+	eventUpdate
+	eventDelete
+)
+
+type event struct {
+	kind   eventKind
+	obj    interface{}
+	oldObj interface{}
+}
+
+// subscriber is an unbounded queue of events delivered in order to a ResourceEventHandler.
+type subscriber struct {
+	handler cache.ResourceEventHandler
+
+	lock    sync.Mutex
+	cond    *sync.Cond
+	events  []event
+	stopped bool
+}
+
+func newSubscriber(h cache.ResourceEventHandler) *subscriber {
+	s := &subscriber{handler: h}
+	s.cond = sync.NewCond(&s.lock)
+	return s
+}
+
+func (s *subscriber) add(e event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+	s.events = append(s.events, e)
+	s.cond.Signal()
+}
+
+func (s *subscriber) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stopped = true
+	s.events = nil
+	s.cond.Signal()
+}
+
+// next blocks until an event is queued, and returns false once the subscriber is stopped.
+func (s *subscriber) next() (event, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for len(s.events) == 0 && !s.stopped {
+		s.cond.Wait()
+	}
+	if s.stopped {
+		return event{}, false
+	}
+	e := s.events[0]
+	s.events[0] = event{}
+	s.events = s.events[1:]
+	return e, true
+}
+
+func (s *subscriber) run() {
+	for {
+		e, ok := s.next()
+		if !ok {
+			return
+		}
+		switch e.kind {
+		case eventAdd:
+			s.handler.OnAdd(e.obj)
+		case eventUpdate:
+			s.handler.OnUpdate(e.oldObj, e.obj)
+		case eventDelete:
+			s.handler.OnDelete(e.obj)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package k8sapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type testSubscriber struct {
+	testWatcher
+	subscriptions map[string]cache.ResourceEventHandler
+}
+
+func (s testSubscriber) Subscriptions() map[string]cache.ResourceEventHandler { return s.subscriptions }
+
+func TestEventFanout(t *testing.T) {
+	block := make(chan struct{})
+	got := make(chan string, 10)
+	slow := cache.ResourceEventHandlerFuncs{AddFunc: func(interface{}) { <-block }}
+	fast := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { got <- "add " + obj.(string) },
+		UpdateFunc: func(_, obj interface{}) { got <- "update " + obj.(string) },
+		DeleteFunc: func(obj interface{}) { got <- "delete " + obj.(string) },
+	}
+	f := newEventFanout([]cache.ResourceEventHandler{slow, fast})
+	f.start()
+	defer f.stop()
+	defer close(block)
+
+	var owned []string
+	events := &eventRouter{fanout: f}
+	h := InformerOptions{events: events}.EventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { owned = append(owned, obj.(string)) },
+	})
+	if !events.used {
+		t.Error("expected event router to be marked used")
+	}
+
+	// The slow subscriber must not block the informer, nor the fast subscriber.
+	done := make(chan struct{})
+	go func() {
+		h.OnAdd("a")
+		h.OnAdd("b")
+		h.OnUpdate("b", "c")
+		h.OnDelete("c")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("informer handler blocked on slow subscriber")
+	}
+	if len(owned) != 2 {
+		t.Errorf("expected owner handler to receive 2 adds, got %v", owned)
+	}
+	for _, want := range []string{"add a", "add b", "update c", "delete c"} {
+		select {
+		case e := <-got:
+			if e != want {
+				t.Errorf("expected event %q, got %q", want, e)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event %q", want)
+		}
+	}
+}
+
+func TestRegisterInformersSubscriber(t *testing.T) {
+	owner := testWatcher{
+		name:      "owner",
+		informers: map[string]InformerFunc{"pod": testInformerFunc(cache.Indexers{})},
+	}
+	consumer := testSubscriber{
+		testWatcher:   testWatcher{name: "consumer"},
+		subscriptions: map[string]cache.ResourceEventHandler{"pod": cache.ResourceEventHandlerFuncs{}},
+	}
+
+	regs, err := registerInformers([]plugin.Handler{owner, consumer})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reg := regs["pod"]
+	if reg.eventFanout() == nil {
+		t.Fatal("expected event fanout for subscribed informer")
+	}
+	// testInformerFunc does not use InformerOptions.EventHandler
+	events := &eventRouter{}
+	reg.fn(context.Background(), nil, InformerOptions{events: events})
+	if err := reg.checkSubscriptions("pod", events); err == nil {
+		t.Error("expected error for informer not supporting subscriptions")
+	}
+	fn := func(_ context.Context, _ kubernetes.Interface, opts InformerOptions) *Informer {
+		opts.EventHandler(nil)
+		return nil
+	}
+	fn(context.Background(), nil, InformerOptions{events: events})
+	if err := reg.checkSubscriptions("pod", events); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// subscription to an informer nobody registers
+	consumer.subscriptions = map[string]cache.ResourceEventHandler{"service": cache.ResourceEventHandlerFuncs{}}
+	if _, err := registerInformers([]plugin.Handler{owner, consumer}); err == nil {
+		t.Error("expected error subscribing to unknown informer")
+	}
+}
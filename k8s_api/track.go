@@ -0,0 +1,62 @@
+package k8sapi
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// listWatchObserver is told of the lists and watches of an Informer in a namespace.
+type listWatchObserver interface {
+	// listed is called with each list returned by the API, or with the error of a failed list.
+	listed(list runtime.Object, options metav1.ListOptions, err error)
+
+	// watched is called when a watch is started, with the error if it failed to start.
+	watched(err error)
+
+	// observe is called with each event of a watch, before it is passed to the Informer.
+	observe(e watch.Event)
+}
+
+// trackedListWatch is the ListerWatcher of an Informer in a namespace, telling its observers of its lists, watches
+// and watch events, so that they share a single wrapper of each watch. The first list is answered from the
+// snapshot, if there is one.
+type trackedListWatch struct {
+	lw        cache.ListerWatcher
+	snapshot  *informerSnapshot
+	observers []listWatchObserver
+}
+
+// List implements cache.Lister.
+func (t *trackedListWatch) List(options metav1.ListOptions) (runtime.Object, error) {
+	if t.snapshot != nil {
+		if list := t.snapshot.restore(options); list != nil {
+			return list, nil
+		}
+	}
+	list, err := t.lw.List(options)
+	for _, o := range t.observers {
+		o.listed(list, options, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Watch implements cache.Watcher.
+func (t *trackedListWatch) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	w, err := t.lw.Watch(options)
+	for _, o := range t.observers {
+		o.watched(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newMultiWatch([]watch.Interface{w}, func(_ int, e watch.Event) {
+		for _, o := range t.observers {
+			o.observe(e)
+		}
+	}), nil
+}
@@ -2,7 +2,12 @@ package k8sapi
 
 import (
 	"context"
+	"reflect"
+	"sync"
 
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 )
@@ -25,6 +30,168 @@ type APIWatcher interface {
 	SetHasSynced(HasSyncedFunc)
 }
 
+// Registrar may be implemented by an APIWatcher to declare the object type and indexers of the Informers it returns
+// from Informers(). If multiple plugins return an Informer with the same name, k8s_api fails to start if they declare
+// different object types, and otherwise adds the indexers declared by all of them to the shared store.
+type Registrar interface {
+	Registrations() map[string]Registration
+}
+
+// Registration declares the object type and indexers of an Informer.
+type Registration struct {
+	// Object is an object of the type held in the Informer's store, e.g. &object.Service{}.
+	Object interface{}
+
+	// Indexers are the indexers the plugin needs in the Informer's store. If multiple plugins declare an index
+	// with the same name, the IndexFunc of the first plugin (per plugin execution order) is used.
+	Indexers cache.Indexers
+
+	// Options identifies the plugin options the Informer is built with, e.g. a label selector, if it is declared by
+	// the plugin providing the InformerFunc. After a Corefile reload, the running Informers of a cluster are reused if
+	// all of them are declared, and their Options and the k8s_api options are unchanged.
+	Options string
+}
+
+// TypedLister is implemented by the stores passed to SetIndexer for Informers whose object type is declared in a
+// Registration, so that plugins can verify the type of the objects in a shared store before using it.
+type TypedLister interface {
+	cache.Indexer
+
+	// ObjectType returns the declared type of the objects in the store.
+	ObjectType() reflect.Type
+}
+
+// IndexProvider may be implemented by an APIWatcher to add indexers to the stores of Informers registered by any
+// plugin, mapped by Informer name. The indexers are added before the Informers start, so plugins can use them to
+// lookup objects in shared stores they do not own. Indexers with the same name as an existing index are ignored.
+type IndexProvider interface {
+	Indexers() map[string]cache.Indexers
+}
+
+// Subscriber may be implemented by an APIWatcher to receive the add, update and delete events of Informers
+// registered by any plugin, mapped by Informer name. Events are queued per subscriber and delivered in order
+// from a separate goroutine, so handlers may block without holding up the Informer or other subscribers.
+type Subscriber interface {
+	Subscriptions() map[string]cache.ResourceEventHandler
+}
+
+// InformerSyncer may be implemented by an APIWatcher that needs to know if individual Informers have synced.
+type InformerSyncer interface {
+	// SetInformerSynced should set the InformerSyncedFunc passed to a local function to be used by the plugin.
+	SetInformerSynced(InformerSyncedFunc)
+}
+
+// HealthReceiver may be implemented by an APIWatcher that needs to know how fresh the objects of Informers are, e.g.
+// to stop answering from them once they are too old.
+type HealthReceiver interface {
+	// SetHealth should set the HealthFunc passed to a local function to be used by the plugin.
+	SetHealth(HealthFunc)
+}
+
+// HasSyncedFunc returns true if all Informers registered by the plugin, or to which it adds indexers, have synced.
 type HasSyncedFunc func() bool
 
-type InformerFunc func(context.Context, kubernetes.Interface) *Informer
+// InformerSyncedFunc returns true if the named Informer exists and has synced.
+type InformerSyncedFunc func(name string) bool
+
+// HealthFunc returns the Health of the named Informer, and false if it does not exist.
+type HealthFunc func(name string) (Health, bool)
+
+// InformerFunc returns an Informer built with the client. The Informer should use InformerOptions.ListerWatcher
+// (or InformerOptions.NamespaceListerWatcher for Namespace objects) to build its ListerWatcher, so that it is
+// scoped to the namespaces and selectors configured in k8s_api, and InformerOptions.EventHandler to build its
+// ResourceEventHandler, so that events are delivered to subscribed plugins. The context is cancelled when k8s_api
+// stops the Informer, so lists and watches made with it should use it, and InformerFromContext returns the cluster
+// and name of the Informer from it.
+type InformerFunc func(context.Context, kubernetes.Interface, InformerOptions) *Informer
+
+// informerContextKey is the key of the informerContextValue of the context passed to an InformerFunc.
+type informerContextKey struct{}
+
+type informerContextValue struct {
+	cluster, informer string
+}
+
+// informerContext returns a context derived from ctx, carrying the cluster and name of an Informer.
+func informerContext(ctx context.Context, cluster, informer string) context.Context {
+	return context.WithValue(ctx, informerContextKey{}, informerContextValue{cluster: cluster, informer: informer})
+}
+
+// InformerFromContext returns the cluster and name of the Informer from the context passed to its InformerFunc,
+// e.g. to attribute logs. The name is as returned by Informers(), without the cluster prefix added by InformerName,
+// and the cluster is empty for the default connection. ok is false if ctx was not passed to an InformerFunc.
+func InformerFromContext(ctx context.Context) (cluster, informer string, ok bool) {
+	v, ok := ctx.Value(informerContextKey{}).(informerContextValue)
+	return v.cluster, v.informer, ok
+}
+
+// InformerOptions are the k8s_api wide options passed to every InformerFunc.
+type InformerOptions struct {
+	// DynamicClient is a dynamic client for the same API connection as the kubernetes.Interface passed to the
+	// InformerFunc. It can be used to watch resources not known to the typed client, e.g. CRDs.
+	DynamicClient dynamic.Interface
+
+	// Namespaces are the namespaces to watch. If empty, all namespaces are watched.
+	Namespaces []string
+
+	// LabelSelector selects the objects to watch, except for Namespace objects.
+	LabelSelector labels.Selector
+
+	// NamespaceLabelSelector selects the Namespace objects to watch.
+	NamespaceLabelSelector labels.Selector
+
+	// FieldSelector selects the objects to watch, except for Namespace objects.
+	FieldSelector fields.Selector
+
+	// informer is the name of the Informer being created, used to name its snapshots.
+	informer string
+
+	// metrics records the metrics of the Informer, if not nil.
+	metrics *informerMetrics
+
+	// events routes the Informer's events to the plugins using it.
+	events *eventRouter
+
+	// snapshots keeps snapshots of the objects of the Informer, identified by snapshotKey, if not nil.
+	snapshots   *snapshotSet
+	snapshotKey string
+
+	// health tracks the lists and watches of the Informer.
+	health *informerHealth
+
+	// build holds the values shared by the InformerFuncs of a build of the Informers of a cluster.
+	build *buildValues
+}
+
+// BuildValue returns the value stored under key for the build of the Informers of the cluster, storing the value
+// returned by newValue if there is none. The InformerFuncs of a build share its values, as do the InformerFuncs
+// called again when a Corefile reload reuses the running Informers, e.g. so that they agree on the result of a
+// discovery request. The values are dropped with the Informers when a stopped cluster is built again. key should be
+// of a type defined by the plugin, to avoid collisions, as for context values.
+func (o InformerOptions) BuildValue(key interface{}, newValue func() interface{}) interface{} {
+	if o.build == nil {
+		return newValue()
+	}
+	return o.build.get(key, newValue)
+}
+
+// buildValues are the values shared by the InformerFuncs of a build.
+type buildValues struct {
+	sync.Mutex
+	values map[interface{}]interface{}
+}
+
+func newBuildValues() *buildValues {
+	return &buildValues{values: make(map[interface{}]interface{})}
+}
+
+func (b *buildValues) get(key interface{}, newValue func() interface{}) interface{} {
+	b.Lock()
+	defer b.Unlock()
+	v, ok := b.values[key]
+	if !ok {
+		v = newValue()
+		b.values[key] = v
+	}
+	return v
+}